@@ -9,6 +9,26 @@ const (
 	EDIT
 	DELETE
 	LIST
+	SSH_SETUP
+	RESTORE
+	RENAME
+	HISTORY
+	UNDO
+	WHERE
+	DOCTOR
+	SCAN
+	HOOK
+	CHECK
+	FIX_AUTHOR
+	EXEC
+	SHELL_INIT
+	ACTIVATE
+	DEACTIVATE
+	PROMPT
+	COMPLETION
+	MAN
+	CONFIG
+	HELP
 )
 
 var actionString = []string{
@@ -18,6 +38,26 @@ var actionString = []string{
 	"edit",
 	"delete",
 	"list",
+	"ssh-setup",
+	"restore",
+	"rename",
+	"history",
+	"undo",
+	"where",
+	"doctor",
+	"scan",
+	"hook",
+	"check",
+	"fix-author",
+	"exec",
+	"shell-init",
+	"activate",
+	"deactivate",
+	"prompt",
+	"completion",
+	"man",
+	"config",
+	"help",
 }
 
 var actionStringToAction = func() map[string]Action {
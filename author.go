@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// gitOutput runs git with args and returns its trimmed output. The error
+// includes what git printed to stderr.
+func gitOutput(args ...string) (string, error) {
+	cmd := manager.Command(context.Background(), args...)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// committerIdentity returns the "Name <email>" git commits with.
+func committerIdentity() (string, error) {
+	ident, err := gitOutput("var", "GIT_COMMITTER_IDENT")
+	if err != nil {
+		return "", err
+	}
+	name, _, _ := strings.Cut(ident, ">")
+	return name + ">", nil
+}
+
+// authorRewrite is the range of commits fix-author rewrites: base..HEAD.
+type authorRewrite struct {
+	Base    string // commit the rewritten commits are on top of
+	Head    string // commit HEAD pointed at before the rewrite
+	Commits int
+	Pushed  int // number of commits that are on a remote branch
+}
+
+// planAuthorRewrite finds the commits to rewrite: the ones after since, or
+// the ones not on the upstream branch if since is empty.
+func planAuthorRewrite(since string) (authorRewrite, error) {
+	inRepo, err := isGitDirectory()
+	if err != nil {
+		return authorRewrite{}, err
+	}
+	if !inRepo {
+		return authorRewrite{}, ErrNotGitDirectory
+	}
+	var plan authorRewrite
+	plan.Head, err = gitOutput("rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return authorRewrite{}, err
+	}
+	if since == "" {
+		if _, err := gitOutput("rev-parse", "--verify", "--quiet", "@{upstream}"); err != nil {
+			return authorRewrite{}, ErrNoUpstream
+		}
+		plan.Base, err = gitOutput("merge-base", "HEAD", "@{upstream}")
+	} else {
+		plan.Base, err = gitOutput("rev-parse", "--verify", since+"^{commit}")
+		if err == nil {
+			err = checkAncestor(plan.Base, since)
+		}
+	}
+	if err != nil {
+		return authorRewrite{}, err
+	}
+
+	commits, err := gitOutput("rev-list", "--count", plan.Base+"..HEAD")
+	if err != nil {
+		return authorRewrite{}, err
+	}
+	unpushed, err := gitOutput("rev-list", "--count", plan.Base+"..HEAD", "--not", "--remotes")
+	if err != nil {
+		return authorRewrite{}, err
+	}
+	plan.Commits, err = strconv.Atoi(commits)
+	if err != nil {
+		return authorRewrite{}, err
+	}
+	n, err := strconv.Atoi(unpushed)
+	if err != nil {
+		return authorRewrite{}, err
+	}
+	plan.Pushed = plan.Commits - n
+	return plan, nil
+}
+
+// checkAncestor returns ErrSinceNotAncestor if commit isn't an ancestor of
+// HEAD, in which case rebasing onto it would drop or rewrite commits that
+// aren't after it.
+func checkAncestor(commit, since string) error {
+	err := manager.Command(context.Background(), "merge-base", "--is-ancestor", commit, "HEAD").Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return fmt.Errorf("%w: %s", ErrSinceNotAncestor, since)
+	}
+	return err
+}
+
+// rewriteAuthor amends every commit of plan with the current identity,
+// signing them if the profile has a signing key. Uncommitted changes are
+// stashed and reapplied by git.
+func rewriteAuthor(plan authorRewrite) error {
+	amend := "git commit --amend --no-edit --no-verify --reset-author"
+	if signingKey, err := gitOutput("config", "user.signingKey"); err == nil && signingKey != "" {
+		amend += " -S"
+	}
+	cmd := manager.Command(context.Background(), "rebase", "--autostash", "--rebase-merges", "--exec", amend, plan.Base)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%w; resolve it and run 'git rebase --continue', or 'git rebase --abort' to go back", err)
+	}
+	return nil
+}
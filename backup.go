@@ -0,0 +1,213 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	backupDirName    = "backups"
+	backupTimeFormat = "20060102T150405.000000000Z"
+	backupOriginFile = "origin" // holds the original path of a global config backup
+)
+
+type BackupKind string
+
+const (
+	GLOBAL_BACKUP  BackupKind = "global"
+	PROFILE_BACKUP BackupKind = "profile"
+)
+
+// Backup is a global config or a profile that was moved aside instead of
+// being deleted.
+type Backup struct {
+	ID   string // name of the backup directory
+	Kind BackupKind
+	Name string // profile name, or the original path of the global config
+	Time time.Time
+}
+
+func (b Backup) path() string {
+	return filepath.Join(saveDirPath, backupDirName, b.ID)
+}
+
+func newBackupID(kind BackupKind, suffix string) string {
+	id := fmt.Sprintf("%s-%s", time.Now().UTC().Format(backupTimeFormat), kind)
+	if suffix != "" {
+		id += "-" + suffix
+	}
+	return id
+}
+
+// backupGlobalConfig moves the global config at configPath into a new backup
+// and returns the backup's path.
+func backupGlobalConfig(configPath string) (string, error) {
+	backup := Backup{ID: newBackupID(GLOBAL_BACKUP, ""), Kind: GLOBAL_BACKUP, Name: configPath}
+	err := os.MkdirAll(backup.path(), 0o744)
+	if err != nil {
+		return "", err
+	}
+	err = os.WriteFile(filepath.Join(backup.path(), backupOriginFile), []byte(configPath), 0o444)
+	if err != nil {
+		return "", err
+	}
+	err = moveFile(configPath, filepath.Join(backup.path(), ".gitconfig"))
+	if err != nil {
+		os.RemoveAll(backup.path())
+		return "", err
+	}
+	return backup.path(), nil
+}
+
+// backupProfile moves the directory of profile into a new backup and returns
+// the backup's path.
+func backupProfile(profile Profile) (string, error) {
+	backup := Backup{ID: newBackupID(PROFILE_BACKUP, profile.DirName), Kind: PROFILE_BACKUP, Name: profile.Name}
+	err := os.MkdirAll(filepath.Dir(backup.path()), 0o744)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(filepath.Join(saveDirPath, profile.DirName), backup.path())
+	if err != nil {
+		return "", err
+	}
+	return backup.path(), nil
+}
+
+// getBackups returns all backups, newest first.
+func getBackups() ([]Backup, error) {
+	entries, err := os.ReadDir(filepath.Join(saveDirPath, backupDirName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		timestamp, rest, ok := strings.Cut(entry.Name(), "-")
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, timestamp)
+		if err != nil {
+			continue
+		}
+		backup := Backup{ID: entry.Name(), Time: t}
+		var nameFile string
+		switch {
+		case rest == string(GLOBAL_BACKUP):
+			backup.Kind, nameFile = GLOBAL_BACKUP, backupOriginFile
+		case strings.HasPrefix(rest, string(PROFILE_BACKUP)+"-"):
+			backup.Kind, nameFile = PROFILE_BACKUP, "profile"
+		default:
+			continue
+		}
+		name, err := os.ReadFile(filepath.Join(backup.path(), nameFile))
+		if err != nil {
+			continue
+		}
+		backup.Name = string(name)
+		backups = append(backups, backup)
+	}
+
+	slices.SortFunc(backups, func(a, b Backup) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	return backups, nil
+}
+
+// restoreBackup moves a backup back to where it came from. A global config
+// that exists at that location is backed up first; a profile that exists
+// with the same name, in any case, is an error. It returns the path of the backup of the
+// replaced global config, if there was one.
+func restoreBackup(backup Backup) (string, error) {
+	switch backup.Kind {
+	case GLOBAL_BACKUP:
+		var replacedBackup string
+		_, err := os.Stat(backup.Name)
+		if err == nil {
+			replacedBackup, err = backupGlobalConfig(backup.Name)
+			if err != nil {
+				return "", err
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		err = os.MkdirAll(filepath.Dir(backup.Name), 0o755)
+		if err != nil {
+			return "", err
+		}
+		err = moveFile(filepath.Join(backup.path(), ".gitconfig"), backup.Name)
+		if err != nil {
+			return "", err
+		}
+		return replacedBackup, os.RemoveAll(backup.path())
+	case PROFILE_BACKUP:
+		// names are unique regardless of case, which the path alone doesn't tell
+		err := manager.ValidateName(backup.Name)
+		if err != nil {
+			return "", err
+		}
+		profilePath := manager.Path(backup.Name)
+		_, err = os.Stat(profilePath)
+		if err == nil {
+			return "", ErrDuplicateProfile
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		return "", os.Rename(backup.path(), profilePath)
+	}
+	return "", ErrBackupNotFound
+}
+
+// moveFile renames src to dst, falling back to copying when they're on
+// different file systems.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		return err
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+const (
+	// remoteBindingKey holds the remotes a profile is meant for, as host/org
+	// prefixes such as github.com/acme.
+	remoteBindingKey = "sw.remote"
+	// directoryBindingKey holds the directories whose repositories a profile
+	// is meant for.
+	directoryBindingKey = "sw.directory"
+)
+
+// validateRemotePattern checks a remote binding given on the command line.
+func validateRemotePattern(s string) error {
+	err := validateNotEmpty(s)
+	if err != nil {
+		return err
+	}
+	if strings.ContainsAny(s, " \t") || strings.Contains(s, "://") {
+		return ErrInvalidRemotePattern
+	}
+	return gitconfig.ValidateValue(s)
+}
+
+// parseRemotePatterns splits a comma-separated list of remote bindings.
+func parseRemotePatterns(s string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(s, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		err := validateRemotePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, strings.Trim(pattern, "/"))
+	}
+	return patterns, nil
+}
+
+// parseDirectoryBindings splits a comma-separated list of directories and
+// makes them absolute.
+func parseDirectoryBindings(s string) ([]string, error) {
+	var dirs []string
+	for _, dir := range strings.Split(s, ",") {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		dir, err := filepath.Abs(expandHome(dir))
+		if err != nil {
+			return nil, err
+		}
+		err = gitconfig.ValidateValue(dir)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, filepath.ToSlash(dir))
+	}
+	return dirs, nil
+}
+
+// addBindings writes the remote and directory bindings given as
+// comma-separated lists to config.
+func addBindings(config *gitconfig.GitConfig, remotes, directories string) error {
+	remotePatterns, err := parseRemotePatterns(remotes)
+	if err != nil {
+		return err
+	}
+	for _, remote := range remotePatterns {
+		err = config.Add(remoteBindingKey, remote)
+		if err != nil {
+			return err
+		}
+	}
+	dirs, err := parseDirectoryBindings(directories)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		err = config.Add(directoryBindingKey, dir)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getAllStrings returns the values of key in config, or nil if it isn't set.
+func getAllStrings(config *gitconfig.GitConfig, key string) []string {
+	values, err := config.GetAll(key)
+	if err != nil {
+		return nil
+	}
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		strs = append(strs, value.String())
+	}
+	return strs
+}
+
+// normalizeRemote turns a remote URL into host/path, without the user, port
+// and .git suffix, e.g. git@github.com:acme/app.git becomes github.com/acme/app.
+// It returns an empty string for local paths.
+func normalizeRemote(remote string) string {
+	var host, path string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil || u.Scheme == "file" {
+			return ""
+		}
+		host, path = u.Hostname(), u.Path
+	} else {
+		// scp-like syntax: [user@]host:path
+		before, after, ok := strings.Cut(remote, ":")
+		if !ok || strings.ContainsAny(before, `/\`) {
+			return ""
+		}
+		_, host, _ = strings.Cut(before, "@")
+		if host == "" {
+			host = before
+		}
+		path = after
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(host) + "/" + path
+}
+
+// matchesRemote reports whether the remote URL is covered by a binding.
+func matchesRemote(pattern, remote string) bool {
+	normalized := normalizeRemote(remote)
+	if normalized == "" {
+		return false
+	}
+	pattern = strings.ToLower(strings.Trim(pattern, "/"))
+	normalized = strings.ToLower(normalized)
+	return normalized == pattern || strings.HasPrefix(normalized, pattern+"/")
+}
+
+// matchesDirectory reports whether dir is the bound directory or inside it.
+func matchesDirectory(binding, dir string) bool {
+	binding, dir = filepath.Clean(filepath.FromSlash(binding)), filepath.Clean(dir)
+	return dir == binding || strings.HasPrefix(dir, binding+string(filepath.Separator))
+}
+
+// profileBinding is a profile together with its remote and directory bindings.
+type profileBinding struct {
+	Profile     Profile
+	Remotes     []string
+	Directories []string
+}
+
+// loadBindings returns the profiles that have bindings.
+func loadBindings(profiles []Profile) ([]profileBinding, error) {
+	var bindings []profileBinding
+	for _, profile := range profiles {
+		if profile.Name == defaultConfigName {
+			continue
+		}
+		config, err := manager.LoadConfig(profile)
+		if err != nil {
+			var parseErr *gitconfig.ParseError
+			if errors.As(err, &parseErr) {
+				continue // reported by doctor
+			}
+			return nil, err
+		}
+		binding := profileBinding{
+			Profile:     profile,
+			Remotes:     getAllStrings(config, remoteBindingKey),
+			Directories: getAllStrings(config, directoryBindingKey),
+		}
+		if len(binding.Remotes) > 0 || len(binding.Directories) > 0 {
+			bindings = append(bindings, binding)
+		}
+	}
+	return bindings, nil
+}
+
+// expectedProfile returns the profile bound to any of the remotes or else to
+// dir, preferring the longest, i.e. most specific, binding.
+func expectedProfile(bindings []profileBinding, remotes []string, dir string) (profileBinding, bool) {
+	var (
+		best       profileBinding
+		bestLength int
+	)
+	for _, binding := range bindings {
+		for _, pattern := range binding.Remotes {
+			for _, remote := range remotes {
+				if matchesRemote(pattern, remote) && len(pattern) > bestLength {
+					best, bestLength = binding, len(pattern)
+				}
+			}
+		}
+	}
+	if bestLength > 0 {
+		return best, true
+	}
+	for _, binding := range bindings {
+		for _, bound := range binding.Directories {
+			if matchesDirectory(bound, dir) && len(bound) > bestLength {
+				best, bestLength = binding, len(bound)
+			}
+		}
+	}
+	return best, bestLength > 0
+}
+
+// identityMismatch explains why the profile used in a repository doesn't fit
+// its remotes and location, or returns an empty string if it does.
+func identityMismatch(bindings []profileBinding, active string, remotes []string, dir string) string {
+	expected, ok := expectedProfile(bindings, remotes, dir)
+	if ok && expected.Profile.Name != active {
+		return fmt.Sprintf("expected profile \"%s\"", expected.Profile.Name)
+	}
+	if ok {
+		return ""
+	}
+	for _, binding := range bindings {
+		if binding.Profile.Name == active {
+			return fmt.Sprintf("repository isn't bound to profile \"%s\"", active)
+		}
+	}
+	return ""
+}
+
+// bindToRemotes binds profile to the remotes of the current repository,
+// unless a profile is already bound to them or to the repository's
+// directory. It returns the bindings it added.
+func bindToRemotes(entry *JournalEntry, profile Profile, profiles []Profile) ([]string, error) {
+	remotes, err := repoRemotes()
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, remote := range remotes {
+		if pattern := normalizeRemote(remote); pattern != "" && !slices.Contains(patterns, pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	repo, _, err := getRepository()
+	if err != nil {
+		return nil, err
+	}
+	dir := repo.WorkTree
+	if dir == "" {
+		dir = repo.GitDir
+	}
+	bindings, err := loadBindings(profiles)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := expectedProfile(bindings, remotes, dir); ok {
+		return nil, nil
+	}
+	configPath, err := manager.ConfigPath(profile)
+	if err != nil {
+		return nil, err
+	}
+	config, err := manager.LoadConfig(profile)
+	if err != nil {
+		return nil, err
+	}
+	err = entry.snapshot(configPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range patterns {
+		err = config.Add(remoteBindingKey, pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return patterns, config.Save(configPath)
+}
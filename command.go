@@ -1,64 +1,126 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+	"github.com/thansetan/git-sw/pkg/profile"
 )
 
 type Command struct {
 	Func        func(app *AppState) error
 	Description string
+	Args        string   // synopsis of the positional arguments, for the usage
+	Flags       []string // names of the flags the command accepts, besides globalFlags
+	ProfileArg  bool     // the first positional argument is the profile, like --profile
+	ReadOnly    bool     // doesn't need the lock, as it doesn't modify anything
+	NoProfiles  bool     // doesn't need the profiles, so they aren't loaded
 }
 
 var commands = map[Action]Command{
 	CREATE: {
 		Description: "Create a new profile.",
+		Args:        "[profile]",
+		Flags:       append(slices.Clone(singleProfileFlags), "from", "partial"),
+		ProfileArg:  true,
 		Func: func(app *AppState) error {
-			profile, err := app.UI.CreateProfile()
+			if fromFlag != "" {
+				return createFrom(app)
+			}
+			created, err := app.UI.CreateProfile()
+			if err != nil {
+				return err
+			}
+			entry := newJournalEntry(CREATE, created.Name)
+			err = entry.snapshotProfile(manager.Path(created.Name))
 			if err != nil {
 				return err
 			}
-			profilePath, err := getProfilePath(profile.Name)
+			_, err = manager.Create(context.Background(), profile.CreateOptions{Name: created.Name, Config: created.Config})
 			if err != nil {
 				return err
 			}
-			err = saveProfile(profilePath, profile.Name, profile.Config)
+			err = entry.record()
 			if err != nil {
 				return err
 			}
-			fmt.Println(successMessage(profile.Name, CREATE))
+			fmt.Fprintln(app.Out, successMessage(created.Name, CREATE))
 			return nil
 		},
 	},
 	USE: {
 		Description: "Select a profile to use.",
+		Args:        "[profile]",
+		Flags:       []string{"g", "scope", "profile"},
+		ProfileArg:  true,
 		Func: func(app *AppState) error {
-			if !isGlobal && !isGitDirectory() {
-				return ErrNotGitDirectory
+			scope, err := manager.UseScope(context.Background(), app.Scope)
+			if err != nil {
+				return err
 			}
+			var (
+				config *gitconfig.GitConfig
+				bound  []string
+			)
 			selected, err := app.UI.SelectProfile(profiles)
 			if err != nil {
 				return err
 			}
+			entry := newJournalEntry(USE, selected.Name)
+			err = entry.snapshotScope(scope)
+			if err != nil {
+				return err
+			}
+			err = registerUse(entry, selected, scope)
+			if err != nil {
+				return err
+			}
+			_, err = manager.Use(context.Background(), selected.Name, profile.UseOptions{Scope: scope})
+			if err != nil {
+				return err
+			}
 			if selected.Name == defaultConfigName {
-				err = unsetConfig(fmt.Sprintf(`%s.*\.gitconfig$`, saveDirName))
-				if err != nil {
-					return err
-				}
 				goto successMsg
 			}
-			err = applyConfig(filepath.Join(saveDirPath, selected.DirName, ".gitconfig"), isGlobal)
+			config, err = manager.LoadConfig(selected)
 			if err != nil {
 				return err
 			}
+			if scope.IsRepoScope() {
+				if getBoolSetting(autoBindSettingKey) {
+					bound, err = bindToRemotes(entry, selected, profiles)
+					if err != nil {
+						return err
+					}
+				}
+			}
 		successMsg:
-			fmt.Println(successMessage(selected.Name, USE))
+			err = entry.record()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(app.Out, successMessage(selected.Name, USE))
+			if len(bound) > 0 {
+				fmt.Fprintf(app.Out, "Bound profile \"%s\" to %s.\n", selected.Name, strings.Join(bound, ", "))
+			}
+			if config != nil {
+				for _, warning := range credentialWarnings(config) {
+					fmt.Fprintln(app.Out, warningMessage(warning))
+				}
+			}
 			return nil
 		},
 	},
 	LIST: {
 		Description: "List all available profiles.",
+		Flags:       []string{"scope"},
+		ReadOnly:    true,
 		Func: func(app *AppState) error {
 			err := app.UI.ListProfiles(profiles)
 			if err != nil {
@@ -69,17 +131,29 @@ var commands = map[Action]Command{
 	},
 	EDIT: {
 		Description: "Edit an existing profile in text editor.",
+		Args:        "[profile]",
+		Flags:       []string{"g", "profile", "credential-url", "credential-username", "credential-helper", "credential-use-http-path"},
+		ProfileArg:  true,
 		Func: func(app *AppState) error {
 			var (
 				selected Profile
 				err      error
+				entry    = newJournalEntry(EDIT, "")
 			)
 			if isGlobal {
-				err = app.UI.EditProfile(filepath.Join(userHomeDir, ".gitconfig"))
+				globalConfigPath, err := gitconfig.GlobalConfigPath()
+				if err != nil {
+					return err
+				}
+				err = entry.snapshot(globalConfigPath)
 				if err != nil {
 					return err
 				}
-				selected.Name = ".gitconfig"
+				err = app.UI.EditProfile(globalConfigPath)
+				if err != nil {
+					return err
+				}
+				selected.Name = globalConfigPath
 				goto successMsg
 			}
 			selected, err = app.UI.SelectProfile(profiles)
@@ -89,29 +163,48 @@ var commands = map[Action]Command{
 			if selected.Name == "default" {
 				return ErrEditDefaultConfig
 			}
-			err = app.UI.EditProfile(filepath.Join(saveDirPath, selected.DirName, ".gitconfig"))
+			err = manager.Edit(context.Background(), selected.Name, profile.EditOptions{
+				Edit: func(ctx context.Context, path string) error {
+					err := entry.snapshot(path)
+					if err != nil {
+						return err
+					}
+					return app.UI.EditProfile(path)
+				},
+			})
 			if err != nil {
 				return err
 			}
 		successMsg:
-			fmt.Println(successMessage(selected.Name, EDIT))
+			entry.Profile = selected.Name
+			err = entry.record()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(app.Out, successMessage(selected.Name, EDIT))
 			return nil
 		},
 	},
 	DELETE: {
 		Description: "Delete an existing profile.",
+		Args:        "[profile]",
+		Flags:       []string{"g", "scope", "profile", "yes"},
+		ProfileArg:  true,
 		Func: func(app *AppState) error {
 			var (
 				selected     Profile
 				err          error
 				deleteGlobal bool
+				backupPath   string
+				cleaned      []RepoUse
+				entry        = newJournalEntry(DELETE, "")
 			)
 			if isGlobal {
-				selected.Name = ".gitconfig"
-				selected.DirName, err = hash(defaultConfigName)
+				selected.Name, err = gitconfig.GlobalConfigPath()
 				if err != nil {
 					return err
 				}
+				selected.DirName = profile.DirName(defaultConfigName)
 				deleteGlobal = app.UI.ConfirmDelete()
 				if !deleteGlobal {
 					return ErrDeleteAborted
@@ -126,22 +219,589 @@ var commands = map[Action]Command{
 				return ErrDeleteDefaultConfig
 			}
 		deleteConfig:
-			err = unsetConfig(fmt.Sprintf(`%s.*%s.\.gitconfig$`, saveDirName, selected.DirName))
+			scopes, err := writeScopes(app.Scope)
 			if err != nil {
 				return err
 			}
-			err = os.RemoveAll(filepath.Join(saveDirPath, selected.DirName))
+			entry.Profile = selected.Name
+			for _, scope := range scopes {
+				err = entry.snapshotScope(scope)
+				if err != nil {
+					return err
+				}
+			}
+			if deleteGlobal {
+				for _, scope := range scopes { // include of the snapshot made by older versions
+					err = manager.UnsetIncludes(context.Background(), manager.ProfileIncludePattern(selected.DirName), scope)
+					if err != nil {
+						return err
+					}
+				}
+				err = entry.snapshot(selected.Name)
+			} else {
+				// the files are kept for the backup
+				err = manager.Delete(context.Background(), selected.Name, profile.DeleteOptions{Scope: app.Scope, KeepFiles: true})
+				if err != nil {
+					return err
+				}
+				err = entry.snapshotProfile(filepath.Join(saveDirPath, selected.DirName))
+				if err != nil {
+					return err
+				}
+				cleaned, err = unregisterProfile(entry, selected)
+			}
 			if err != nil {
 				return err
 			}
 			if deleteGlobal {
-				err = os.Remove(filepath.Join(userHomeDir, ".gitconfig"))
+				err = os.RemoveAll(filepath.Join(saveDirPath, selected.DirName)) // snapshot made by older versions
+				if err != nil {
+					return err
+				}
+				backupPath, err = backupGlobalConfig(selected.Name)
+			} else {
+				backupPath, err = backupProfile(selected)
+			}
+			if err != nil {
+				return err
+			}
+			entry.Backup = backupPath
+			err = entry.record()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(app.Out, successMessage(selected.Name, DELETE))
+			for _, use := range cleaned {
+				fmt.Fprintf(app.Out, "Removed its include from %s\n", use.Config)
+			}
+			fmt.Fprintf(app.Out, "Backup saved to %s (use '%s restore' to undo)\n", backupPath, progName)
+			return nil
+		},
+	},
+	SSH_SETUP: {
+		Description: "Write SSH host aliases for profiles that have an SSH key.",
+		Flags:       []string{"ssh-host"},
+		Func: func(app *AppState) error {
+			entry := newJournalEntry(SSH_SETUP, "")
+			configured, err := setupSSHHosts(entry, profiles, sshHostFlag)
+			if recordErr := entry.record(); err == nil {
+				err = recordErr
+			}
+			if err != nil {
+				return err
+			}
+			for _, profile := range configured {
+				fmt.Fprintf(app.Out, "%s (Host %s)\n", successMessage(profile.Name, SSH_SETUP), sshHostAlias(sshHostFlag, profile.Name))
+			}
+			return nil
+		},
+	},
+	RESTORE: {
+		Description: "Restore a deleted profile or global config from a backup.",
+		Flags:       []string{"backup"},
+		Func: func(app *AppState) error {
+			backups, err := getBackups()
+			if err != nil {
+				return err
+			}
+			if len(backups) == 0 {
+				return ErrNoBackups
+			}
+			selected, err := app.UI.SelectBackup(backups)
+			if err != nil {
+				return err
+			}
+			replacedBackup, err := restoreBackup(selected)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(app.Out, successMessage(selected.Name, RESTORE))
+			if replacedBackup != "" {
+				fmt.Fprintf(app.Out, "Previous global config saved to %s\n", replacedBackup)
+			}
+			return nil
+		},
+	},
+	RENAME: {
+		Description: "Rename an existing profile.",
+		Args:        "[profile] [new-name]",
+		Flags:       []string{"profile", "new-name"},
+		ProfileArg:  true,
+		Func: func(app *AppState) error {
+			selected, err := app.UI.SelectProfile(profiles)
+			if err != nil {
+				return err
+			}
+			if selected.Name == defaultConfigName {
+				return ErrRenameDefaultConfig
+			}
+			if app.Arg(0) != "" {
+				newNameFlag = app.Arg(0)
+			}
+			newName, err := app.UI.RenameProfile(selected, profiles)
+			if err != nil {
+				return err
+			}
+			oldPath := filepath.Join(saveDirPath, selected.DirName)
+			newPath := manager.Path(newName)
+			entry := newJournalEntry(RENAME, newName)
+			err = entry.snapshotProfile(oldPath)
+			if err != nil {
+				return err
+			}
+			err = entry.snapshotProfile(newPath)
+			if err != nil {
+				return err
+			}
+
+			// the includes that point to the profile have to follow it: the ones
+			// in the registry, and the ones written before the registry existed
+			registry, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			uses, err := registry.usedIn(selected)
+			if err != nil {
+				return err
+			}
+			scopes, err := writeScopes("")
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(scopes, GLOBAL) {
+				scopes = append(scopes, GLOBAL)
+			}
+			for _, scope := range scopes {
+				use, err := newRepoUse(scope)
+				if err != nil {
+					return err
+				}
+				included, err := profileIncludedIn(selected, use.Config)
+				if err != nil {
+					return err
+				}
+				if included && !slices.ContainsFunc(uses, func(u RepoUse) bool { return u.Config == use.Config }) {
+					uses = append(uses, use)
+				}
+			}
+			err = entry.snapshot(registryPath())
+			if err != nil {
+				return err
+			}
+			for _, use := range uses {
+				err = entry.snapshotRepoUse(use)
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err = manager.Rename(context.Background(), selected.Name, newName)
+			if err != nil {
+				return err
+			}
+			for _, use := range uses {
+				err = replaceIncludeFile(filepath.Join(newPath, ".gitconfig"), manager.ProfileIncludePattern(selected.DirName), use.Config)
+				if err != nil {
+					return err
+				}
+			}
+			delete(registry, selected.DirName)
+			if len(uses) > 0 {
+				registry[filepath.Base(newPath)] = uses
+			}
+			err = registry.save()
+			if err != nil {
+				return err
+			}
+			err = entry.record()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(app.Out, "%s (now \"%s\")\n", successMessage(selected.Name, RENAME), newName)
+			return nil
+		},
+	},
+	HISTORY: {
+		Description: "List the changes made by git-sw.",
+		ReadOnly:    true,
+		Func: func(app *AppState) error {
+			entries, err := getJournal()
+			if err != nil {
+				return err
+			}
+			return app.UI.ListHistory(entries)
+		},
+	},
+	UNDO: {
+		Description: "Revert the last change made by git-sw.",
+		Func: func(app *AppState) error {
+			entries, err := getJournal()
+			if err != nil {
+				return err
+			}
+			last, err := lastUndoableEntry(entries)
+			if err != nil {
+				return err
+			}
+			entry := newJournalEntry(UNDO, last.Profile)
+			entry.Undoes, entry.Repos = last.ID, last.Repos
+			for _, file := range last.Files {
+				err = entry.snapshot(file.Path)
 				if err != nil {
 					return err
 				}
 			}
-			fmt.Println(successMessage(selected.Name, DELETE))
+			err = last.revert()
+			if err != nil {
+				return err
+			}
+			if last.Backup != "" {
+				// the deleted files are back, so restore mustn't offer them again
+				err = os.RemoveAll(last.Backup)
+				if err != nil {
+					return err
+				}
+			}
+			err = entry.save()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(app.Out, "%s (reverted '%s' from %s)\n", successMessage(last.Profile, UNDO), last.Command, last.Time.Local().Format("2006-01-02 15:04:05"))
 			return nil
 		},
 	},
+	WHERE: {
+		Description: "List the repositories a profile is used in.",
+		Args:        "[profile]",
+		Flags:       []string{"profile"},
+		ProfileArg:  true,
+		ReadOnly:    true,
+		Func: func(app *AppState) error {
+			selected, err := app.UI.SelectProfile(profiles)
+			if err != nil {
+				return err
+			}
+			if selected.Name == defaultConfigName {
+				return ErrWhereDefaultConfig
+			}
+			registry, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			uses, err := registry.usedIn(selected)
+			if err != nil {
+				return err
+			}
+			return app.UI.ListRepos(selected, uses)
+		},
+	},
+	DOCTOR: {
+		Description: "Find problems with profiles and includes, and repair them with --fix.",
+		Flags:       []string{"fix", "yes", "email"},
+		Func: func(app *AppState) error {
+			issues, err := diagnose(app)
+			if err != nil {
+				return err
+			}
+			if len(issues) == 0 {
+				fmt.Fprintln(app.Out, "No problems found.")
+				return nil
+			}
+			var (
+				entry = newJournalEntry(DOCTOR, "")
+				fixed int
+			)
+			for _, issue := range issues {
+				fmt.Fprintln(app.Out, warningMessage(issue.Description))
+				if !fixFlag {
+					continue
+				}
+				if issue.Fix == nil {
+					fmt.Fprintln(app.Out, "  This has to be fixed by hand.")
+					continue
+				}
+				if !app.UI.Confirm(issue.FixLabel) {
+					continue
+				}
+				err = issue.Fix(entry)
+				if err != nil {
+					break
+				}
+				fixed++
+			}
+			if recordErr := entry.record(); err == nil {
+				err = recordErr
+			}
+			if err != nil {
+				return err
+			}
+			if !fixFlag {
+				fmt.Fprintf(app.Out, "Run '%s doctor --fix' to repair them.\n", progName)
+			} else {
+				fmt.Fprintf(app.Out, "Fixed %d of %d problems.\n", fixed, len(issues))
+			}
+			if fixed < len(issues) {
+				return ErrProblemsLeft
+			}
+			return nil
+		},
+	},
+	SCAN: {
+		Description: "Report the profile, email and remotes of every repository under a directory.",
+		Args:        "[dir]",
+		ReadOnly:    true,
+		Func: func(app *AppState) error {
+			root := cmp.Or(app.Arg(0), ".")
+			results, err := scanRepos(root, profiles)
+			if err != nil {
+				return err
+			}
+			return app.UI.ListScan(results)
+		},
+	},
+	HOOK: {
+		Description: "Install or uninstall a pre-commit hook that runs 'check', per repository or globally with -g.",
+		Args:        "install|uninstall",
+		Flags:       []string{"g"},
+		Func: func(app *AppState) error {
+			var (
+				install, uninstall = installRepoHook, uninstallRepoHook
+				entry              = newJournalEntry(HOOK, "")
+				path               string
+				err                error
+			)
+			if isGlobal {
+				install, uninstall = installGlobalHooks, uninstallGlobalHooks
+			}
+			subcommand := app.Arg(0)
+			switch subcommand {
+			case "install":
+				path, err = install(entry)
+			case "uninstall":
+				path, err = uninstall(entry)
+			default:
+				return ErrInvalidHookCommand
+			}
+			if recordErr := entry.record(); err == nil {
+				err = recordErr
+			}
+			if err != nil {
+				return err
+			}
+			if subcommand == "install" {
+				fmt.Fprintf(app.Out, "Installed the git-sw hook at %s\n", path)
+			} else {
+				fmt.Fprintf(app.Out, "Removed the git-sw hook from %s\n", path)
+			}
+			return nil
+		},
+	},
+	CHECK: {
+		Description: "Check that the identity git commits with matches the profile bound to this repository.",
+		ReadOnly:    true,
+		Func: func(app *AppState) error {
+			return checkIdentity(profiles)
+		},
+	},
+	FIX_AUTHOR: {
+		Description: "Rewrite the author and committer of the commits not yet on the upstream branch.",
+		Flags:       []string{"since", "force", "yes"},
+		Func: func(app *AppState) error {
+			plan, err := planAuthorRewrite(sinceFlag)
+			if err != nil {
+				return err
+			}
+			if plan.Commits == 0 {
+				fmt.Fprintln(app.Out, "No commits to rewrite.")
+				return nil
+			}
+			if plan.Pushed > 0 && !forceFlag {
+				return fmt.Errorf("%w (%d of %d commits)", ErrPushedCommits, plan.Pushed, plan.Commits)
+			}
+			ident, err := committerIdentity()
+			if err != nil {
+				return err
+			}
+			if err := checkIdentity(profiles); err != nil {
+				fmt.Fprintln(app.Out, warningMessage(err.Error()))
+			}
+			if !app.UI.Confirm(fmt.Sprintf("Rewrite %d commits as %s", plan.Commits, ident)) {
+				return ErrRewriteAborted
+			}
+			err = rewriteAuthor(plan)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(app.Out, "Rewrote %d commits as %s. The old history is at %s.\n", plan.Commits, ident, plan.Head)
+			return nil
+		},
+	},
+	EXEC: {
+		Description: "Run a command with a profile applied through the environment, without switching to it.",
+		Args:        "[profile] -- command [args...]",
+		Flags:       []string{"profile"},
+		ProfileArg:  true,
+		ReadOnly:    true,
+		Func: func(app *AppState) error {
+			command := app.Args
+			if len(command) > 0 && command[0] == "--" {
+				command = command[1:]
+			}
+			if len(command) == 0 {
+				return ErrMissingCommand
+			}
+			selected, err := app.UI.SelectProfile(profiles)
+			if err != nil {
+				return err
+			}
+			return execWithProfile(selected, command)
+		},
+	},
+	SHELL_INIT: {
+		Description: "Print the shell integration for bash, zsh, or fish, which adds activate and deactivate.",
+		Args:        "bash|zsh|fish",
+		ReadOnly:    true,
+		NoProfiles:  true,
+		Func: func(app *AppState) error {
+			script, err := shellInit(app.Arg(0))
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(app.Out, script)
+			return nil
+		},
+	},
+	ACTIVATE: {
+		Description: "Use a profile in the current shell session only (needs shell-init).",
+		Args:        "[profile]",
+		Flags:       []string{"profile"},
+		ProfileArg:  true,
+		ReadOnly:    true,
+		Func: func(app *AppState) error {
+			if profileFlag == "" {
+				return ErrMissingProfile
+			}
+			selected, err := findProfile(profiles, profileFlag)
+			if err != nil {
+				return err
+			}
+			script, err := activateScript(selected)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(app.Out, script)
+			fmt.Fprintln(os.Stderr, successMessage(selected.Name, ACTIVATE))
+			return nil
+		},
+	},
+	DEACTIVATE: {
+		Description: "Stop using the profile activated in the current shell session.",
+		ReadOnly:    true,
+		NoProfiles:  true,
+		Func: func(app *AppState) error {
+			script, err := deactivateScript()
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(app.Out, script)
+			return nil
+		},
+	},
+	PROMPT: {
+		Description: "Print the name of the profile in effect, for use in a shell prompt.",
+		ReadOnly:    true,
+		NoProfiles:  true,
+		Func: func(app *AppState) error {
+			if name := promptProfile(); name != "" {
+				fmt.Fprintln(app.Out, name)
+			}
+			return nil
+		},
+	},
+	CONFIG: {
+		Description: "Get or set the settings of git-sw.",
+		Args:        "get <key> | set <key> <value> | unset <key> | list",
+		ReadOnly:    true, // the settings file isn't part of the state the lock guards
+		NoProfiles:  true,
+		Func: func(app *AppState) error {
+			switch app.Arg(0) {
+			case "get":
+				s, err := findSetting(app.Arg(1))
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(app.Out, getSetting(s.Key))
+			case "set":
+				if len(app.Args) != 3 {
+					return fmt.Errorf("%w: 'set' takes a key and a value", ErrInvalidConfigCommand)
+				}
+				s, err := setSetting(app.ConfigDir, app.Arg(1), app.Arg(2))
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(app.Out, "Set %s to %s.\n", s.Key, app.Arg(2))
+				if os.Getenv(s.Env) != "" {
+					fmt.Fprintln(app.Out, warningMessage(fmt.Sprintf("%s is set, which overrides it", s.Env)))
+				}
+			case "unset":
+				s, err := unsetSetting(app.ConfigDir, app.Arg(1))
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(app.Out, "Unset %s, it's %s by default.\n", s.Key, s.defaultValue(app.ConfigDir))
+			case "list":
+				for _, s := range settings {
+					fmt.Fprintf(app.Out, "%s=%s\n", s.Key, getSetting(s.Key))
+				}
+			default:
+				return ErrInvalidConfigCommand
+			}
+			return nil
+		},
+	},
+}
+
+func init() {
+	// registered here, as they're built from the other commands
+	commands[HELP] = Command{
+		Description: "Show the arguments and options of a command.",
+		Args:        "[command]",
+		ReadOnly:    true,
+		NoProfiles:  true,
+		Func: func(app *AppState) error {
+			if app.Arg(0) == "" {
+				printUsage(app.Out)
+				return nil
+			}
+			action := getAction(strings.ToLower(app.Arg(0)))
+			if !action.IsValid() {
+				return fmt.Errorf("%w: %s", ErrInvalidAction, app.Arg(0))
+			}
+			printCommandUsage(app.Out, action)
+			return nil
+		},
+	}
+	commands[COMPLETION] = Command{
+		Description: "Print the completion script for bash, zsh, fish, or powershell.",
+		Args:        "bash|zsh|fish|powershell",
+		ReadOnly:    true,
+		NoProfiles:  true,
+		Func: func(app *AppState) error {
+			script, err := completionScript(app.Arg(0))
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(app.Out, script)
+			return nil
+		},
+	}
+	commands[MAN] = Command{
+		Description: "Print the manual page, for 'git help sw'.",
+		ReadOnly:    true,
+		NoProfiles:  true,
+		Func: func(app *AppState) error {
+			fmt.Fprint(app.Out, manPage())
+			return nil
+		},
+	}
 }
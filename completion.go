@@ -0,0 +1,529 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completionKind is what the value of a flag or a positional argument is
+// completed with.
+type completionKind int
+
+const (
+	completeNothing completionKind = iota
+	completeProfiles
+	completeFiles
+	completeDirs
+	completeWords
+)
+
+type completion struct {
+	Kind  completionKind
+	Words []string // for completeWords
+}
+
+func wordCompletion[T ~string](words []T) completion {
+	c := completion{Kind: completeWords}
+	for _, w := range words {
+		c.Words = append(c.Words, string(w))
+	}
+	return c
+}
+
+// flagCompletions are the flags whose values can be completed. Other flags
+// that take a value get no suggestions.
+var flagCompletions = map[string]completion{
+	"C":           {Kind: completeDirs},
+	"git-dir":     {Kind: completeDirs},
+	"output":      wordCompletion([]string{textOutput, jsonOutput}),
+	"profile":     {Kind: completeProfiles},
+	"key-format":  wordCompletion(gpgFormat),
+	"scope":       wordCompletion(scopes),
+	"signing-key": {Kind: completeFiles},
+	"ssh-key":     {Kind: completeFiles},
+	"directory":   {Kind: completeDirs},
+	"from":        {Kind: completeFiles},
+}
+
+// argCompletions are the commands whose positional arguments can be completed.
+var argCompletions = map[Action]completion{
+	USE:        {Kind: completeProfiles},
+	EDIT:       {Kind: completeProfiles},
+	DELETE:     {Kind: completeProfiles},
+	RENAME:     {Kind: completeProfiles},
+	WHERE:      {Kind: completeProfiles},
+	EXEC:       {Kind: completeProfiles},
+	ACTIVATE:   {Kind: completeProfiles},
+	SCAN:       {Kind: completeDirs},
+	HOOK:       wordCompletion([]string{"install", "uninstall"}),
+	SHELL_INIT: wordCompletion(initShells),
+	COMPLETION: wordCompletion(completionShells),
+	CONFIG:     wordCompletion([]string{"get", "set", "unset", "list"}),
+}
+
+type completionFlag struct {
+	Name, Usage string
+	IsBool      bool
+}
+
+// option returns how the flag is written, "-g" for single letters and
+// "--name" otherwise, although both forms are accepted.
+func (f completionFlag) option() string {
+	if len(f.Name) == 1 {
+		return "-" + f.Name
+	}
+	return "--" + f.Name
+}
+
+// completionFlags returns the flags registered by parseFlag.
+func completionFlags() []completionFlag {
+	var flags []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			Name:   f.Name,
+			Usage:  f.Usage,
+			IsBool: ok && boolFlag.IsBoolFlag(),
+		})
+	})
+	return flags
+}
+
+// valueFlagPattern returns the options that take a value, in both forms,
+// joined with sep.
+func valueFlagPattern(flags []completionFlag, sep string) string {
+	var options []string
+	for _, f := range flags {
+		if !f.IsBool {
+			options = append(options, "-"+f.Name, "--"+f.Name)
+		}
+	}
+	return strings.Join(options, sep)
+}
+
+// flagsOf returns the flags action accepts after the command.
+func flagsOf(flags []completionFlag, action Action) []completionFlag {
+	var accepted []completionFlag
+	for _, f := range flags {
+		if commands[action].acceptsFlag(f.Name) {
+			accepted = append(accepted, f)
+		}
+	}
+	return accepted
+}
+
+func options(flags []completionFlag) []string {
+	var options []string
+	for _, f := range flags {
+		options = append(options, f.option())
+	}
+	return options
+}
+
+// completionScript returns the completion script for shell.
+func completionScript(shell string) (string, error) {
+	flags := completionFlags()
+	switch shell {
+	case "bash":
+		return bashCompletion(flags), nil
+	case "zsh":
+		return zshCompletion(flags), nil
+	case "fish":
+		return fishCompletion(flags), nil
+	case "powershell":
+		return powershellCompletion(flags), nil
+	}
+	return "", ErrInvalidCompletionShell
+}
+
+func bashCompletion(flags []completionFlag) string {
+	sb := new(strings.Builder)
+	sb.WriteString(`# bash completion for git-sw, generated by 'git-sw completion bash'
+# _git_sw_profiles adds the profiles starting with $cur to COMPREPLY,
+# quoted as they have to be typed.
+_git_sw_profiles() {
+	local profile
+	while IFS= read -r profile; do
+		profile="${profile% (active)}"
+		[[ "$profile" == "$cur"* ]] && COMPREPLY+=("$(printf '%q' "$profile")")
+	done < <(git-sw --no-tui list 2>/dev/null)
+}
+
+_git_sw_complete() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+	local IFS=$'\n'
+	COMPREPLY=()
+`)
+	bashReply := func(indent string, c completion) {
+		switch c.Kind {
+		case completeProfiles:
+			fmt.Fprintf(sb, "%s_git_sw_profiles\n", indent)
+		case completeFiles:
+			fmt.Fprintf(sb, "%sCOMPREPLY=($(compgen -f -- \"$cur\"))\n", indent)
+		case completeDirs:
+			fmt.Fprintf(sb, "%sCOMPREPLY=($(compgen -d -- \"$cur\"))\n", indent)
+		case completeWords:
+			fmt.Fprintf(sb, "%sCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", indent, shellQuote(strings.Join(c.Words, "\n")))
+		}
+	}
+
+	sb.WriteString("\tcase \"$prev\" in\n")
+	for _, f := range flags {
+		if f.IsBool {
+			continue
+		}
+		fmt.Fprintf(sb, "\t-%[1]s|--%[1]s)\n", f.Name)
+		bashReply("\t\t", flagCompletions[f.Name])
+		sb.WriteString("\t\treturn\n\t\t;;\n")
+	}
+	sb.WriteString("\tesac\n\n")
+
+	fmt.Fprintf(sb, `	local i command=
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case "${COMP_WORDS[i]}" in
+		-*) ;;
+		*)
+			case "${COMP_WORDS[i-1]}" in
+			%s) ;;
+			*)
+				command="${COMP_WORDS[i]}"
+				break
+				;;
+			esac
+			;;
+		esac
+	done
+
+`, valueFlagPattern(flags, "|"))
+
+	sb.WriteString("\tif [[ \"$cur\" == -* ]]; then\n\t\tcase \"$command\" in\n\t\t\"\")\n")
+	bashReply("\t\t\t", wordCompletion(options(flags)))
+	sb.WriteString("\t\t\t;;\n")
+	for _, name := range actionString[1:] {
+		fmt.Fprintf(sb, "\t\t%s)\n", name)
+		bashReply("\t\t\t", wordCompletion(options(flagsOf(flags, getAction(name)))))
+		sb.WriteString("\t\t\t;;\n")
+	}
+	sb.WriteString("\t\tesac\n\t\treturn\n\tfi\n")
+	sb.WriteString("\tcase \"$command\" in\n\t\"\")\n")
+	bashReply("\t\t", wordCompletion(actionString[1:]))
+	sb.WriteString("\t\t;;\n")
+	for _, action := range sortedArgActions() {
+		fmt.Fprintf(sb, "\t%s)\n", action)
+		bashReply("\t\t", argCompletions[action])
+		sb.WriteString("\t\t;;\n")
+	}
+	sb.WriteString(`	esac
+}
+
+complete -F _git_sw_complete git-sw
+
+# git's completion calls _git_sw for "git sw", with the command line in
+# $words and the index of "sw" in it in $__git_cmd_idx.
+_git_sw() {
+	local idx="${__git_cmd_idx:-1}"
+	local -a COMP_WORDS=(git-sw "${words[@]:idx+1}")
+	local COMP_CWORD=$((cword - idx))
+	_git_sw_complete
+}
+`)
+	return sb.String()
+}
+
+// zshDescription escapes s for a description in an _arguments spec or a
+// _describe item.
+func zshDescription(s string) string {
+	return strings.NewReplacer(`'`, `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func zshAction(c completion) string {
+	switch c.Kind {
+	case completeProfiles:
+		return "_git_sw_profiles"
+	case completeFiles:
+		return "_files"
+	case completeDirs:
+		return "_files -/"
+	case completeWords:
+		return "(" + strings.Join(c.Words, " ") + ")"
+	}
+	return " "
+}
+
+// zshFlagSpecs writes the _arguments specs of flags, one per line.
+func zshFlagSpecs(sb *strings.Builder, indent string, flags []completionFlag) {
+	for _, f := range flags {
+		if f.IsBool {
+			fmt.Fprintf(sb, "%s'%s[%s]' \\\n", indent, f.option(), zshDescription(f.Usage))
+			continue
+		}
+		fmt.Fprintf(sb, "%s'%s=[%s]:%s:%s' \\\n", indent, f.option(), zshDescription(f.Usage), f.Name, zshAction(flagCompletions[f.Name]))
+	}
+}
+
+func zshCompletion(flags []completionFlag) string {
+	sb := new(strings.Builder)
+	sb.WriteString(`#compdef git-sw
+# zsh completion for git-sw, generated by 'git-sw completion zsh'
+
+_git_sw_profiles() {
+	local -a profiles
+	profiles=(${${(f)"$(git-sw --no-tui list 2>/dev/null)"}% \(active\)})
+	_wanted profiles expl profile compadd -a profiles
+}
+
+_git-sw() {
+	local curcontext="$curcontext" state line
+	local -a commands
+	commands=(
+`)
+	for _, name := range actionString[1:] {
+		fmt.Fprintf(sb, "\t\t'%s:%s'\n", name, zshDescription(commands[getAction(name)].Description))
+	}
+	sb.WriteString("\t)\n\n\t_arguments -C \\\n")
+	zshFlagSpecs(sb, "\t\t", flags)
+	sb.WriteString(`		'1:command:->command' \
+		'*::argument:->argument'
+
+	case $state in
+	command)
+		_describe -t commands command commands
+		;;
+	argument)
+		case $words[1] in
+`)
+	for _, name := range actionString[1:] {
+		action := getAction(name)
+		fmt.Fprintf(sb, "\t\t%s)\n\t\t\t_arguments \\\n", name)
+		zshFlagSpecs(sb, "\t\t\t\t", flagsOf(flags, action))
+		if c, ok := argCompletions[action]; ok {
+			fmt.Fprintf(sb, "\t\t\t\t'*:argument:%s'\n", zshAction(c))
+		} else {
+			sb.WriteString("\t\t\t\t'*: :'\n")
+		}
+		sb.WriteString("\t\t\t;;\n")
+	}
+	sb.WriteString(`		esac
+		;;
+	esac
+}
+
+if [ "$funcstack[1]" = "_git-sw" ]; then
+	_git-sw "$@"
+else
+	compdef _git-sw git-sw
+fi
+`)
+	return sb.String()
+}
+
+func fishCompletion(flags []completionFlag) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, `# fish completion for git-sw, generated by 'git-sw completion fish'
+function __git_sw_profiles
+	git-sw --no-tui list 2>/dev/null | string replace -r ' \(active\)$' ''
+end
+
+# __git_sw_command prints the command given so far, skipping options and
+# their values.
+function __git_sw_command
+	set -l tokens (commandline -opc)
+	set -e tokens[1]
+	set -l skip 0
+	for token in $tokens
+		if test $skip -eq 1
+			set skip 0
+			continue
+		end
+		switch $token
+			case %s
+				set skip 1
+			case '-*'
+			case '*'
+				echo $token
+				return 0
+		end
+	end
+	return 1
+end
+
+function __git_sw_command_is
+	contains -- (__git_sw_command) $argv
+end
+
+complete -c git-sw -f
+`, valueFlagPattern(flags, " "))
+
+	fishArgs := func(c completion) string {
+		switch c.Kind {
+		case completeProfiles:
+			return " -x -a '(__git_sw_profiles)'"
+		case completeFiles:
+			return " -r -F"
+		case completeDirs:
+			return " -x -a '(__fish_complete_directories)'"
+		case completeWords:
+			return fmt.Sprintf(" -x -a %s", fishQuote(strings.Join(c.Words, " ")))
+		}
+		return " -x"
+	}
+	for _, name := range actionString[1:] {
+		fmt.Fprintf(sb, "complete -c git-sw -n 'not __git_sw_command' -a %s -d %s\n", name, fishQuote(commands[getAction(name)].Description))
+	}
+	for _, f := range flags {
+		option := "-l " + f.Name
+		if len(f.Name) == 1 {
+			option = "-s " + f.Name
+		}
+		if !slices.Contains(globalFlags, f.Name) {
+			var names []string
+			for _, name := range actionString[1:] {
+				if slices.Contains(commands[getAction(name)].Flags, f.Name) {
+					names = append(names, name)
+				}
+			}
+			option += fmt.Sprintf(" -n 'not __git_sw_command; or __git_sw_command_is %s'", strings.Join(names, " "))
+		}
+		args := ""
+		if !f.IsBool {
+			args = fishArgs(flagCompletions[f.Name])
+		}
+		fmt.Fprintf(sb, "complete -c git-sw %s -d %s%s\n", option, fishQuote(f.Usage), args)
+	}
+	for _, action := range sortedArgActions() {
+		fmt.Fprintf(sb, "complete -c git-sw -n '__git_sw_command_is %s'%s\n", action, fishArgs(argCompletions[action]))
+	}
+	return sb.String()
+}
+
+// psQuote quotes s for PowerShell.
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func powershellCompletion(flags []completionFlag) string {
+	sb := new(strings.Builder)
+	sb.WriteString(`# PowerShell completion for git-sw, generated by 'git-sw completion powershell'
+Register-ArgumentCompleter -Native -CommandName 'git-sw' -ScriptBlock {
+	param($wordToComplete, $commandAst, $cursorPosition)
+
+	$commands = [ordered]@{
+`)
+	for _, name := range actionString[1:] {
+		fmt.Fprintf(sb, "\t\t%s = %s\n", psQuote(name), psQuote(commands[getAction(name)].Description))
+	}
+	sb.WriteString("\t}\n\t$options = [ordered]@{\n")
+	for _, f := range flags {
+		fmt.Fprintf(sb, "\t\t%s = %s\n", psQuote(f.option()), psQuote(f.Usage))
+	}
+	var valueFlags []string
+	for _, option := range strings.Split(valueFlagPattern(flags, " "), " ") {
+		valueFlags = append(valueFlags, psQuote(option))
+	}
+	fmt.Fprintf(sb, "\t}\n\t$valueOptions = @(%s)\n", strings.Join(valueFlags, ", "))
+	sb.WriteString("\t$commandOptions = @{\n")
+	for _, name := range actionString[1:] {
+		var quoted []string
+		for _, option := range options(flagsOf(flags, getAction(name))) {
+			quoted = append(quoted, psQuote(option))
+		}
+		fmt.Fprintf(sb, "\t\t%s = @(%s)\n", psQuote(name), strings.Join(quoted, ", "))
+	}
+	sb.WriteString("\t}\n")
+
+	psValues := func(c completion) string {
+		switch c.Kind {
+		case completeProfiles:
+			return "$profiles"
+		case completeFiles:
+			return "$files"
+		case completeDirs:
+			return "$dirs"
+		case completeWords:
+			var words []string
+			for _, w := range c.Words {
+				words = append(words, psQuote(w))
+			}
+			return "@(" + strings.Join(words, ", ") + ")"
+		}
+		return "@()"
+	}
+	sb.WriteString(`
+	$profiles = { git-sw --no-tui list 2>$null | ForEach-Object { $_ -replace ' \(active\)$', '' } }
+	$files = { Get-ChildItem -Path "$wordToComplete*" -Name -ErrorAction SilentlyContinue }
+	$dirs = { Get-ChildItem -Path "$wordToComplete*" -Directory -Name -ErrorAction SilentlyContinue }
+
+	$words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+	if ($wordToComplete -ne '' -and $words.Count -gt 0) {
+		$words = @($words | Select-Object -SkipLast 1)
+	}
+	$previous = if ($words.Count -gt 0) { $words[-1] } else { '' }
+
+	$command = ''
+	for ($i = 0; $i -lt $words.Count; $i++) {
+		if ($words[$i] -like '-*') { continue }
+		if ($i -gt 0 -and $valueOptions -contains $words[$i - 1]) { continue }
+		$command = $words[$i]
+		break
+	}
+
+	$candidates = switch ($previous) {
+`)
+	for _, f := range flags {
+		if f.IsBool {
+			continue
+		}
+		c := flagCompletions[f.Name]
+		values := psValues(c)
+		if c.Kind == completeProfiles || c.Kind == completeFiles || c.Kind == completeDirs {
+			values = "& " + values
+		}
+		fmt.Fprintf(sb, "\t\t{ $_ -in '-%[1]s', '--%[1]s' } { %[2]s; break }\n", f.Name, values)
+	}
+	sb.WriteString(`		default {
+			if ($wordToComplete -like '-*' -and $command -eq '') {
+				$options.Keys
+			} elseif ($wordToComplete -like '-*') {
+				$commandOptions[$command]
+			} elseif ($command -eq '') {
+				$commands.Keys
+			} else {
+				switch ($command) {
+`)
+	for _, action := range sortedArgActions() {
+		c := argCompletions[action]
+		values := psValues(c)
+		if c.Kind == completeProfiles || c.Kind == completeFiles || c.Kind == completeDirs {
+			values = "& " + values
+		}
+		fmt.Fprintf(sb, "\t\t\t\t\t%s { %s }\n", psQuote(action.String()), values)
+	}
+	sb.WriteString(`				}
+			}
+		}
+	}
+
+	$candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+		$text = if ($_ -match '\s') { "'" + ($_ -replace "'", "''") + "'" } else { $_ }
+		$tooltip = if ($commands.Contains($_)) { $commands[$_] } elseif ($options.Contains($_)) { $options[$_] } else { $_ }
+		[System.Management.Automation.CompletionResult]::new($text, $_, 'ParameterValue', $tooltip)
+	}
+}
+`)
+	return sb.String()
+}
+
+// sortedArgActions returns the commands in argCompletions in the order of
+// actionString, so the scripts don't change between runs.
+func sortedArgActions() []Action {
+	var actions []Action
+	for action := range argCompletions {
+		actions = append(actions, action)
+	}
+	slices.Sort(actions)
+	return actions
+}
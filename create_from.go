@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/profile"
+)
+
+// csvHeader is the first row of a CSV file for create --from, which is
+// skipped. Columns after these are extra key=value settings.
+var csvHeader = []string{"profile", "name", "email", "signing_key", "key_format"}
+
+// singleProfileFlags are the flags of create that describe a single profile.
+// Except for --gpg-program, the default of every record, they can't be
+// combined with --from.
+var singleProfileFlags = []string{"profile", "name", "email", "signing-key", "key-format", "gpg-program", "ssh-key", "remote", "directory", "credential-url", "credential-username", "credential-helper", "credential-use-http-path"}
+
+// fromRecord is a record of create --from and where it was read.
+type fromRecord struct {
+	Record  profileRecord
+	Line    int
+	Profile Profile // the profile it describes, if it's valid
+	Err     error
+}
+
+// createFrom creates the profiles of the records in the file of --from. By
+// default, none is created unless they're all valid.
+func createFrom(app *AppState) error {
+	for _, name := range singleProfileFlags {
+		if name != "gpg-program" && isFlagSet(name) {
+			return fmt.Errorf("%w: --%s", ErrFromConflict, name)
+		}
+	}
+	if profileFlag != "" {
+		return fmt.Errorf("%w: profile %q", ErrFromConflict, profileFlag)
+	}
+
+	r := app.In
+	if fromFlag != "-" {
+		f, err := os.Open(expandHome(fromFlag))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	records, err := readProfileRecords(r)
+	if err != nil {
+		return err
+	}
+
+	// validate every record first, also against the ones before it
+	known := profiles
+	var invalid int
+	for i := range records {
+		record := &records[i]
+		if record.Err == nil {
+			record.Profile, record.Err = record.Record.newProfile(known)
+		}
+		if record.Err != nil {
+			invalid++
+			continue
+		}
+		known = append(known[:len(known):len(known)], record.Profile)
+	}
+	if invalid > 0 && !partialFlag {
+		for _, record := range records {
+			if record.Err != nil {
+				fmt.Fprintf(app.Out, "line %d: %s\n", record.Line, formatError(record.Err))
+			} else {
+				fmt.Fprintf(app.Out, "line %d: profile \"%s\" is valid\n", record.Line, record.Record.Profile)
+			}
+		}
+		return ErrInvalidRecords
+	}
+
+	var names []string
+	for _, record := range records {
+		if record.Err == nil {
+			names = append(names, record.Profile.Name)
+		}
+	}
+	entry := newJournalEntry(CREATE, strings.Join(names, ", "))
+	for _, name := range names {
+		err = entry.snapshotProfile(manager.Path(name))
+		if err != nil {
+			return err
+		}
+	}
+	for i := range records {
+		record := &records[i]
+		if record.Err != nil {
+			continue
+		}
+		_, err = manager.Create(context.Background(), profile.CreateOptions{Name: record.Profile.Name, Config: record.Profile.Config})
+		if err != nil && !partialFlag {
+			return errors.Join(err, entry.revert())
+		}
+		record.Err = err
+	}
+	err = entry.record()
+	if err != nil {
+		return err
+	}
+
+	invalid = 0
+	for _, record := range records {
+		if record.Err != nil {
+			invalid++
+			fmt.Fprintf(app.Out, "line %d: %s\n", record.Line, formatError(record.Err))
+		} else {
+			fmt.Fprintf(app.Out, "line %d: %s\n", record.Line, successMessage(record.Profile.Name, CREATE))
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%w: %d of %d profiles weren't created", ErrInvalidRecord, invalid, len(records))
+	}
+	return nil
+}
+
+// readProfileRecords reads the records of create --from from r, as JSON Lines
+// if it starts with '{', otherwise as CSV. A record that can't be read has
+// its Err set, while errors reading r are returned.
+func readProfileRecords(r io.Reader) ([]fromRecord, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if !strings.Contains(" \t\r\n", string(b)) {
+			if b[0] == '{' {
+				return readJSONRecords(br)
+			}
+			return readCSVRecords(br)
+		}
+		br.ReadByte()
+	}
+}
+
+func readJSONRecords(r io.Reader) ([]fromRecord, error) {
+	var records []fromRecord
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		record := fromRecord{Line: line}
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&record.Record)
+		if err != nil {
+			record.Err = fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+func readCSVRecords(r io.Reader) ([]fromRecord, error) {
+	var records []fromRecord
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	for first := true; ; first = false {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			records = append(records, fromRecord{Line: parseErr.StartLine, Err: fmt.Errorf("%w: %w", ErrInvalidRecord, parseErr.Err)})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && strings.EqualFold(strings.TrimSpace(fields[0]), csvHeader[0]) {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		record := fromRecord{Line: line}
+		fields = append(fields, make([]string, max(len(csvHeader)-len(fields), 0))...)
+		record.Record = profileRecord{
+			Profile:    strings.TrimSpace(fields[0]),
+			Name:       strings.TrimSpace(fields[1]),
+			Email:      strings.TrimSpace(fields[2]),
+			SigningKey: strings.TrimSpace(fields[3]),
+			KeyFormat:  strings.TrimSpace(fields[4]),
+		}
+		for _, setting := range fields[len(csvHeader):] {
+			if setting = strings.TrimSpace(setting); setting != "" {
+				record.Record.Settings = append(record.Record.Settings, setting)
+			}
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+const credentialTimeout = 5 * time.Second
+
+func validateCredentialURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ErrInvalidCredentialURL
+	}
+	return gitconfig.ValidateValue(s)
+}
+
+// credentialUsernames returns the credential.<url>.username values of config, keyed by URL.
+func credentialUsernames(config *gitconfig.GitConfig) map[string]string {
+	usernames := make(map[string]string)
+	for _, key := range config.Keys() {
+		if key.Section.Name != "credential" || key.Section.Subsection == "" || key.Name != "username" {
+			continue
+		}
+		val, err := config.Get(key.String())
+		if err != nil {
+			continue
+		}
+		usernames[key.Section.Subsection] = val.String()
+	}
+	return usernames
+}
+
+// cachedCredentialUsername asks git's credential helpers for a stored credential
+// of rawURL, without ever prompting the user. It returns an empty string if
+// there's no stored credential. config is the profile, whose
+// credential.<url>.username would otherwise be handed to the helpers and
+// come back as the username of any credential.
+func cachedCredentialUsername(rawURL string, config *gitconfig.GitConfig) string {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialTimeout)
+	defer cancel()
+
+	tempDir, err := os.MkdirTemp("", "git-sw-credential-")
+	if err != nil {
+		return ""
+	}
+	defer os.RemoveAll(tempDir)
+	configEnv, err := credentialConfigEnv(config, tempDir)
+	if err != nil {
+		return ""
+	}
+
+	cmd := manager.Command(ctx, "credential", "fill")
+	cmd.Dir = tempDir // outside of any repository, so only the global and system credential config is used
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("url=%s\n\n", rawURL))
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=", "GCM_INTERACTIVE=never")
+	cmd.Env = append(cmd.Env, configEnv...)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if username, ok := strings.CutPrefix(scanner.Text(), "username="); ok {
+			return username
+		}
+	}
+	return ""
+}
+
+// credentialConfigEnv returns the environment that makes git read the global
+// and system configs without the profile includes, from copies written to
+// dir, and the credential settings of config other than the usernames, the
+// way they'd be read through the include.
+func credentialConfigEnv(config *gitconfig.GitConfig, dir string) ([]string, error) {
+	globalPaths, err := gitconfig.GlobalConfigPaths()
+	if err != nil {
+		return nil, err
+	}
+	var global []byte
+	for _, path := range globalPaths {
+		content, err := credentialConfigCopy(path)
+		if err != nil {
+			return nil, err
+		}
+		global = append(global, content...)
+	}
+	env := []string{"GIT_CONFIG_GLOBAL=" + filepath.Join(dir, "global")}
+	err = os.WriteFile(filepath.Join(dir, "global"), global, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if systemPath := gitconfig.SystemConfigPath(); systemPath != "" {
+		system, err := credentialConfigCopy(systemPath)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, "GIT_CONFIG_SYSTEM="+filepath.Join(dir, "system"))
+		err = os.WriteFile(filepath.Join(dir, "system"), system, 0o600)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var count int
+	for _, key := range config.Keys() {
+		if key.Section.Name != "credential" || key.Name == "username" {
+			continue
+		}
+		values, err := config.GetAll(key.String())
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count, key), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count, value.String()))
+			count++
+		}
+	}
+	return append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", count)), nil
+}
+
+// credentialConfigCopy returns the config file at path without its profile
+// includes, and with its relative includes made absolute, so that it can be
+// read from another directory. A file that doesn't exist is empty.
+func credentialConfigCopy(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, content, err = splitProfileIncludes(path, content)
+	if err != nil {
+		return nil, err
+	}
+	f, err := gitconfig.ParseFile(path, content)
+	if err != nil {
+		return nil, err
+	}
+	includes, err := f.GetAll("include.path")
+	if err != nil && !errors.Is(err, gitconfig.ErrKeyNotFound) {
+		return nil, err
+	}
+	for _, include := range includes {
+		p := include.String()
+		if filepath.IsAbs(p) || strings.HasPrefix(p, "~") {
+			continue
+		}
+		err = f.ReplaceAll("include.path", filepath.Join(filepath.Dir(path), p), regexp.MustCompile("^"+regexp.QuoteMeta(p)+"$"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(f.Bytes(), '\n'), nil
+}
+
+// credentialWarnings returns a warning for every credential.<url>.username of
+// config whose cached credential belongs to a different username.
+func credentialWarnings(config *gitconfig.GitConfig) []string {
+	var warnings []string
+	for rawURL, username := range credentialUsernames(config) {
+		cached := cachedCredentialUsername(rawURL, config)
+		if cached != "" && cached != username {
+			warnings = append(warnings, fmt.Sprintf("cached credential for %s belongs to %q, but this profile uses %q", rawURL, cached, username))
+		}
+	}
+	slices.Sort(warnings)
+	return warnings
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/profile"
+	"golang.org/x/crypto/ssh"
+)
+
+// doctorIssue is a problem found by doctor. Fix is nil if it can't be
+// repaired automatically.
+type doctorIssue struct {
+	Description string
+	FixLabel    string
+	Fix         func(entry *JournalEntry) error
+}
+
+// diagnose looks for broken state in the profiles and in the config files
+// git-sw knows about.
+func diagnose(app *AppState) ([]doctorIssue, error) {
+	configFiles, issues, err := doctorConfigFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	dirIssues, err := diagnoseProfileDirs(configFiles)
+	if err != nil {
+		return nil, err
+	}
+	issues = append(issues, dirIssues...)
+	includeIssues, err := diagnoseIncludes(configFiles)
+	if err != nil {
+		return nil, err
+	}
+	issues = append(issues, includeIssues...)
+	for _, profile := range profiles {
+		if profile.Name == defaultConfigName {
+			continue
+		}
+		issues = append(issues, diagnoseProfile(app, profile)...)
+	}
+	return issues, nil
+}
+
+// doctorConfigFiles returns the config files that may include profiles: the
+// global and system configs, the ones of the current repository and the ones
+// in the registry. A repository that can't be read is an issue, and only its
+// config files are left out.
+func doctorConfigFiles() ([]string, []doctorIssue, error) {
+	var issues []doctorIssue
+	scopes := []Scope{GLOBAL, SYSTEM}
+	inRepo, err := isGitDirectory()
+	if err != nil {
+		issues = append(issues, doctorIssue{Description: fmt.Sprintf("can't read the current repository: %v", err)})
+	} else if inRepo {
+		repoScopes, err := writeScopes("")
+		if err != nil {
+			return nil, nil, err
+		}
+		scopes = append(scopes, repoScopes...)
+	}
+	var paths []string
+	for _, scope := range scopes {
+		path, err := scopeFilePath(scope)
+		if err != nil {
+			return nil, nil, err
+		}
+		paths = append(paths, path)
+	}
+	registry, err := loadRegistry()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, uses := range registry {
+		for _, use := range uses {
+			paths = append(paths, use.Config)
+		}
+	}
+
+	var configFiles []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); path == "" || err != nil || slices.Contains(configFiles, path) {
+			continue
+		}
+		configFiles = append(configFiles, path)
+	}
+	return configFiles, issues, nil
+}
+
+// diagnoseProfileDirs finds profile directories that manager.List skips,
+// because their profile file is missing or doesn't match the directory name.
+func diagnoseProfileDirs(configFiles []string) ([]doctorIssue, error) {
+	entries, err := os.ReadDir(saveDirPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defaultDirName := profile.DirName(defaultConfigName)
+
+	var issues []doctorIssue
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == backupDirName || entry.Name() == hooksDirName || entry.Name() == defaultDirName {
+			continue
+		}
+		dirPath := filepath.Join(saveDirPath, entry.Name())
+		profileName, err := os.ReadFile(filepath.Join(dirPath, "profile"))
+		if err != nil {
+			issues = append(issues, doctorIssue{
+				Description: fmt.Sprintf("directory %s has no readable profile file, so it isn't listed as a profile", dirPath),
+			})
+			continue
+		}
+		dirName := profile.DirName(string(profileName))
+		if dirName == entry.Name() {
+			continue
+		}
+		issue := doctorIssue{
+			Description: fmt.Sprintf("directory %s of profile \"%s\" doesn't match the profile name, so it isn't listed as a profile", dirPath, profileName),
+		}
+		newPath := filepath.Join(saveDirPath, dirName)
+		if _, err := os.Stat(newPath); err == nil {
+			issue.Description += fmt.Sprintf(", and %s is taken by another profile", newPath)
+			issues = append(issues, issue)
+			continue
+		}
+		issue.FixLabel = fmt.Sprintf("Move it to %s", newPath)
+		issue.Fix = func(journal *JournalEntry) error {
+			return moveProfileDir(journal, entry.Name(), dirName, configFiles)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// moveProfileDir moves a profile directory and points the includes of it in
+// configFiles and in the registry to the new location.
+func moveProfileDir(entry *JournalEntry, oldDirName, newDirName string, configFiles []string) error {
+	oldPath, newPath := filepath.Join(saveDirPath, oldDirName), filepath.Join(saveDirPath, newDirName)
+	old := Profile{DirName: oldDirName}
+	var includedIn []string
+	for _, path := range configFiles {
+		included, err := profileIncludedIn(old, path)
+		if err != nil {
+			return err
+		}
+		if included {
+			includedIn = append(includedIn, path)
+		}
+	}
+	err := entry.snapshotProfile(oldPath)
+	if err != nil {
+		return err
+	}
+	err = entry.snapshotProfile(newPath)
+	if err != nil {
+		return err
+	}
+	err = entry.snapshot(includedIn...)
+	if err != nil {
+		return err
+	}
+	err = entry.snapshot(registryPath())
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+	for _, path := range includedIn {
+		err = replaceIncludeFile(filepath.Join(newPath, ".gitconfig"), manager.ProfileIncludePattern(oldDirName), path)
+		if err != nil {
+			return err
+		}
+	}
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if uses, ok := registry[oldDirName]; ok {
+		delete(registry, oldDirName)
+		registry[newDirName] = uses
+		return registry.save()
+	}
+	return nil
+}
+
+// diagnoseIncludes finds git-sw includes of profiles that don't exist, and
+// config files with more than one git-sw include.
+func diagnoseIncludes(configFiles []string) ([]doctorIssue, error) {
+	var issues []doctorIssue
+	for _, path := range configFiles {
+		includes, err := getAllFile("include.path", path)
+		if err != nil {
+			return nil, err
+		}
+		var managed, existing []string
+		for _, include := range includes {
+			if !manager.IncludePattern().MatchString(include) {
+				continue
+			}
+			managed = append(managed, include)
+			if _, err := os.Stat(include); err == nil || !errors.Is(err, os.ErrNotExist) {
+				existing = append(existing, include)
+				continue
+			}
+			issues = append(issues, doctorIssue{
+				Description: fmt.Sprintf("%s includes %s, which doesn't exist", path, include),
+				FixLabel:    fmt.Sprintf("Remove the include from %s", path),
+				Fix: func(entry *JournalEntry) error {
+					if _, err := os.Stat(include); err == nil { // brought back by moving its directory
+						return nil
+					}
+					err := entry.snapshot(path)
+					if err != nil {
+						return err
+					}
+					return unsetConfigFile("^"+regexp.QuoteMeta(include)+"$", path)
+				},
+			})
+		}
+		if len(managed) > 1 && len(existing) > 0 {
+			last := existing[len(existing)-1]
+			issues = append(issues, doctorIssue{
+				Description: fmt.Sprintf("%s has %d git-sw includes, but there should be only one", path, len(managed)),
+				FixLabel:    fmt.Sprintf("Keep only %s", last),
+				Fix: func(entry *JournalEntry) error {
+					err := entry.snapshot(path)
+					if err != nil {
+						return err
+					}
+					return replaceIncludeFile(last, manager.IncludePattern().String(), path)
+				},
+			})
+		}
+	}
+	return issues, nil
+}
+
+// diagnoseProfile finds profiles without user.email and keys that can't be read.
+func diagnoseProfile(app *AppState, profile Profile) []doctorIssue {
+	config, err := manager.LoadConfig(profile)
+	if err != nil {
+		return []doctorIssue{{Description: fmt.Sprintf("profile \"%s\" can't be read: %s", profile.Name, err)}}
+	}
+
+	var issues []doctorIssue
+	if _, err := config.Get("user.email"); err != nil {
+		issues = append(issues, doctorIssue{
+			Description: fmt.Sprintf("profile \"%s\" has no user.email", profile.Name),
+			FixLabel:    fmt.Sprintf("Set user.email of profile \"%s\"", profile.Name),
+			Fix: func(entry *JournalEntry) error {
+				email, err := app.UI.PromptEmail(profile)
+				if err != nil {
+					return err
+				}
+				err = config.Set("user.email", email)
+				if err != nil {
+					return err
+				}
+				configPath, err := manager.ConfigPath(profile)
+				if err != nil {
+					return err
+				}
+				err = entry.snapshot(configPath)
+				if err != nil {
+					return err
+				}
+				return config.Save(configPath)
+			},
+		})
+	}
+
+	var pubKeys []string
+	if format, err := config.Get("gpg.format"); err == nil && GPGFormat(format.String()) == SSH {
+		if signingKey, err := config.Get("user.signingKey"); err == nil && strings.HasSuffix(signingKey.String(), ".pub") {
+			pubKeys = append(pubKeys, signingKey.String())
+		}
+	}
+	if command, err := config.Get("core.sshCommand"); err == nil {
+		if identityPath := sshIdentityFromCommand(command.String()); identityPath != "" {
+			pubKeys = append(pubKeys, identityPath+".pub")
+		}
+	}
+	for _, pubKey := range pubKeys {
+		content, err := os.ReadFile(expandHome(pubKey))
+		if err == nil {
+			_, _, _, _, err = ssh.ParseAuthorizedKey(content)
+		}
+		if err != nil {
+			issues = append(issues, doctorIssue{
+				Description: fmt.Sprintf("public key %s of profile \"%s\" can't be read: %s", pubKey, profile.Name, err),
+			})
+		}
+	}
+	return issues
+}
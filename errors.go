@@ -3,18 +3,169 @@ package main
 import (
 	"errors"
 	"fmt"
-	"os"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+	"github.com/thansetan/git-sw/pkg/profile"
 )
 
 var (
-	ErrEmptyField          = errors.New("field can't be empty")
-	ErrInvalidEmail        = errors.New("invalid email format")
-	ErrDuplicateProfile    = errors.New("profile with given name already exists")
-	ErrInvalidAction       = errors.New("invalid action")
-	ErrNotImplemented      = errors.New("not implemented")
-	ErrEditDefaultConfig   = fmt.Errorf("use '%s -g edit' to edit default config", os.Args[0])
-	ErrDeleteDefaultConfig = fmt.Errorf("use '%s -g delete' to delete default config", os.Args[0])
-	ErrDeleteAborted       = errors.New("delete aborted: confirmation required")
-	ErrInvalidPublicKeyExt = errors.New("invalid public key file extension")
-	ErrNotGitDirectory     = errors.New("not in a git directory")
+	ErrEmptyField             = errors.New("field can't be empty")
+	ErrInvalidEmail           = errors.New("invalid email format")
+	ErrDuplicateProfile       = profile.ErrDuplicateProfile
+	ErrInvalidAction          = errors.New("invalid command")
+	ErrNotImplemented         = errors.New("not implemented")
+	ErrEditDefaultConfig      = fmt.Errorf("use '%s edit -g' to edit default config", progName)
+	ErrDeleteDefaultConfig    = fmt.Errorf("use '%s delete -g' to delete default config", progName)
+	ErrDeleteAborted          = errors.New("delete aborted: confirmation required")
+	ErrInvalidPublicKeyExt    = errors.New("invalid public key file extension")
+	ErrNotGitDirectory        = profile.ErrNotGitDirectory
+	ErrNoBackups              = errors.New("there are no backups to restore")
+	ErrBackupNotFound         = errors.New("backup not found")
+	ErrInvalidScope           = errors.New("invalid scope: must be 'local', 'worktree', 'global', or 'system'")
+	ErrScopeConflict          = errors.New("flag -g can't be combined with a --scope other than 'global'")
+	ErrWorktreeConfigDisabled = profile.ErrWorktreeConfigDisabled
+	ErrInvalidPrivateKeyExt   = errors.New("SSH identity must be a private key, not a .pub file")
+	ErrSSHKeyMismatch         = errors.New("SSH private key doesn't match its .pub file")
+	ErrInvalidCredentialURL   = errors.New("invalid credential URL: must be an http(s) URL with a host")
+	ErrUnsupportedKeyPath     = errors.New("SSH key path can't contain quotes, backslashes, '#' or ';'")
+	ErrRenameDefaultConfig    = errors.New("the default profile can't be renamed")
+	ErrNothingToUndo          = errors.New("there is nothing to undo")
+	ErrWhereDefaultConfig     = errors.New("the default profile is used wherever no other profile is")
+	ErrProblemsLeft           = errors.New("doctor found problems that weren't fixed")
+	ErrNotDirectory           = errors.New("not a directory")
+	ErrInvalidRemotePattern   = errors.New("invalid remote: must be a host/org prefix such as github.com/acme")
+	ErrLockTimeout            = errors.New("timed out waiting for another git-sw process to finish")
+	ErrInvalidHookCommand     = errors.New("invalid hook command: must be 'install' or 'uninstall'")
+	ErrHookExists             = errors.New("a pre-commit hook that isn't managed by git-sw already exists")
+	ErrHookNotManaged         = errors.New("the pre-commit hook isn't managed by git-sw")
+	ErrHooksPathSet           = errors.New("core.hooksPath is already set in the global config")
+	ErrIdentityMismatch       = errors.New("identity doesn't match the profile of this repository")
+	ErrNoIdentity             = errors.New("no committer identity")
+	ErrNoUpstream             = errors.New("the current branch has no upstream branch: use --since <ref> to choose the commits to rewrite")
+	ErrPushedCommits          = errors.New("refusing to rewrite commits that have been pushed: use --force to rewrite them anyway")
+	ErrRewriteAborted         = errors.New("rewrite aborted: confirmation required")
+	ErrInvalidShell           = errors.New("invalid shell: must be 'bash', 'zsh', or 'fish'")
+	ErrInvalidCompletionShell = errors.New("invalid shell: must be 'bash', 'zsh', 'fish', or 'powershell'")
+	ErrShellNotInitialized    = fmt.Errorf("activate and deactivate need the shell integration: add 'eval \"$(%s shell-init bash)\"' or the zsh/fish equivalent to your shell's startup file", progName)
+	ErrProfileArgConflict     = errors.New("the profile is given both as an argument and with --profile")
+	ErrUnknownSetting         = fmt.Errorf("unknown setting, see '%s config list'", progName)
+	ErrRelativeStorageDir     = errors.New("storage directory must be an absolute path or start with '~/'")
+	ErrInvalidBool            = errors.New("not a boolean")
+	ErrInvalidConfigCommand   = errors.New("invalid config command: must be 'get', 'set', 'unset', or 'list'")
+	ErrInvalidFlag            = errors.New("invalid option")
+	ErrInvalidOutput          = errors.New("invalid output format: must be 'text' or 'json'")
+	ErrMissingCommand         = fmt.Errorf("missing command: use '%s exec <profile> -- <command> [args...]'", progName)
+	ErrInvalidRecord          = errors.New("invalid record")
+	ErrInvalidRecords         = errors.New("no profile was created because some records are invalid")
+	ErrFromConflict           = errors.New("--from can't be combined with the flags of a single profile")
+	ErrUndoConflict           = errors.New("can't undo, the file was changed since")
+	ErrSinceNotAncestor       = errors.New("--since must be an ancestor of HEAD")
+	ErrSSHAliasConflict       = errors.New("profiles have the same SSH host alias")
 )
+
+// errorCode is the stable code and exit status of a sentinel error, so
+// scripts can tell errors apart without matching their messages.
+type errorCode struct {
+	Err    error
+	Code   string
+	Status int
+}
+
+// unknownErrorCode is used for errors that don't wrap a sentinel error.
+var unknownErrorCode = errorCode{Code: "UNKNOWN", Status: 1}
+
+// errorCodes must never be renumbered: new errors get the next free status.
+var errorCodes = []errorCode{
+	{ErrEmptyField, "EMPTY_FIELD", 10},
+	{ErrInvalidEmail, "INVALID_EMAIL", 11},
+	{ErrDuplicateProfile, "DUPLICATE_PROFILE", 12},
+	{ErrInvalidAction, "INVALID_COMMAND", 13},
+	{ErrNotImplemented, "NOT_IMPLEMENTED", 14},
+	{ErrEditDefaultConfig, "EDIT_DEFAULT_CONFIG", 15},
+	{ErrDeleteDefaultConfig, "DELETE_DEFAULT_CONFIG", 16},
+	{ErrDeleteAborted, "DELETE_ABORTED", 17},
+	{ErrInvalidPublicKeyExt, "INVALID_PUBLIC_KEY_EXTENSION", 18},
+	{ErrNotGitDirectory, "NOT_GIT_DIRECTORY", 19},
+	{ErrNoBackups, "NO_BACKUPS", 20},
+	{ErrBackupNotFound, "BACKUP_NOT_FOUND", 21},
+	{ErrInvalidScope, "INVALID_SCOPE", 22},
+	{ErrScopeConflict, "SCOPE_CONFLICT", 23},
+	{ErrWorktreeConfigDisabled, "WORKTREE_CONFIG_DISABLED", 24},
+	{ErrInvalidPrivateKeyExt, "INVALID_PRIVATE_KEY_EXTENSION", 25},
+	{ErrSSHKeyMismatch, "SSH_KEY_MISMATCH", 26},
+	{ErrInvalidCredentialURL, "INVALID_CREDENTIAL_URL", 27},
+	{ErrUnsupportedKeyPath, "UNSUPPORTED_KEY_PATH", 28},
+	{ErrRenameDefaultConfig, "RENAME_DEFAULT_CONFIG", 29},
+	{ErrNothingToUndo, "NOTHING_TO_UNDO", 30},
+	{ErrWhereDefaultConfig, "WHERE_DEFAULT_CONFIG", 31},
+	{ErrProblemsLeft, "PROBLEMS_LEFT", 32},
+	{ErrNotDirectory, "NOT_DIRECTORY", 33},
+	{ErrInvalidRemotePattern, "INVALID_REMOTE_PATTERN", 34},
+	{ErrLockTimeout, "LOCK_TIMEOUT", 35},
+	{ErrInvalidHookCommand, "INVALID_HOOK_COMMAND", 36},
+	{ErrHookExists, "HOOK_EXISTS", 37},
+	{ErrHookNotManaged, "HOOK_NOT_MANAGED", 38},
+	{ErrHooksPathSet, "HOOKS_PATH_SET", 39},
+	{ErrIdentityMismatch, "IDENTITY_MISMATCH", 40},
+	{ErrNoIdentity, "NO_IDENTITY", 41},
+	{ErrNoUpstream, "NO_UPSTREAM", 42},
+	{ErrPushedCommits, "PUSHED_COMMITS", 43},
+	{ErrRewriteAborted, "REWRITE_ABORTED", 44},
+	{ErrInvalidShell, "INVALID_SHELL", 45},
+	{ErrInvalidCompletionShell, "INVALID_COMPLETION_SHELL", 46},
+	{ErrShellNotInitialized, "SHELL_NOT_INITIALIZED", 47},
+	{ErrProfileArgConflict, "PROFILE_ARG_CONFLICT", 48},
+	{ErrUnknownSetting, "UNKNOWN_SETTING", 49},
+	{ErrRelativeStorageDir, "RELATIVE_STORAGE_DIR", 50},
+	{ErrInvalidBool, "INVALID_BOOLEAN", 51},
+	{ErrInvalidConfigCommand, "INVALID_CONFIG_COMMAND", 52},
+	{ErrMissingCommand, "MISSING_COMMAND", 53},
+	{ErrMissingProfile, "MISSING_PROFILE", 54},
+	{ErrMissingName, "MISSING_NAME", 55},
+	{ErrMissingEmail, "MISSING_EMAIL", 56},
+	{ErrProfileNotFound, "PROFILE_NOT_FOUND", 57},
+	{ErrEditNoTUI, "EDIT_NO_TUI", 58},
+	{ErrDeleteNoConfirm, "DELETE_NO_CONFIRM", 59},
+	{ErrInvalidKeyFormat, "INVALID_KEY_FORMAT", 60},
+	{ErrMissingSigningKey, "MISSING_SIGNING_KEY", 61},
+	{ErrMissingBackup, "MISSING_BACKUP", 62},
+	{ErrMissingCredURL, "MISSING_CREDENTIAL_URL", 63},
+	{ErrMissingNewName, "MISSING_NEW_NAME", 64},
+	{ErrInvalidFlag, "INVALID_FLAG", 65},
+	{ErrInvalidOutput, "INVALID_OUTPUT", 66},
+	{ErrInvalidSetting, "INVALID_SETTING", 67},
+	{ErrInvalidRecord, "INVALID_RECORD", 68},
+	{ErrInvalidRecords, "INVALID_RECORDS", 69},
+	{ErrFromConflict, "FROM_CONFLICT", 70},
+	{ErrUndoConflict, "UNDO_CONFLICT", 71},
+	{ErrSinceNotAncestor, "SINCE_NOT_ANCESTOR", 72},
+	{ErrSSHAliasConflict, "SSH_ALIAS_CONFLICT", 73},
+	{profile.ErrEmptyName, "EMPTY_NAME", 74},
+	{profile.ErrDefaultProfile, "DEFAULT_PROFILE", 75},
+	{gitconfig.ErrLocked, "CONFIG_LOCKED", 76},
+	{gitconfig.ErrInvalidKey, "INVALID_CONFIG_KEY", 77},
+	{gitconfig.ErrKeyNotFound, "CONFIG_KEY_NOT_FOUND", 78},
+	{gitconfig.ErrInvalidValueType, "INVALID_CONFIG_VALUE_TYPE", 79},
+	{gitconfig.ErrEmptyValue, "EMPTY_CONFIG_VALUE", 80},
+	{gitconfig.ErrInvalidSection, "INVALID_CONFIG_SECTION", 81},
+	{gitconfig.ErrInvalidSubsection, "INVALID_CONFIG_SUBSECTION", 82},
+	{gitconfig.ErrInvalidVariableName, "INVALID_CONFIG_VARIABLE_NAME", 83},
+	{gitconfig.ErrInvalidVariableValue, "INVALID_CONFIG_VARIABLE_VALUE", 84},
+	{gitconfig.ErrInvalidLine, "INVALID_CONFIG_LINE", 85},
+	{gitconfig.ErrNotLocked, "CONFIG_NOT_LOCKED", 86},
+	{gitconfig.ErrUnencodableValue, "UNENCODABLE_CONFIG_VALUE", 87},
+	// the same errors as the ones of git-sw, reported by the packages
+	{profile.ErrRelativeStorageDir, "RELATIVE_STORAGE_DIR", 50},
+	{gitconfig.ErrInvalidBool, "INVALID_BOOLEAN", 51},
+	{gitconfig.ErrNoRepository, "NOT_GIT_DIRECTORY", 19},
+}
+
+// lookupErrorCode returns the code of the first sentinel error err wraps.
+func lookupErrorCode(err error) errorCode {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.Err) {
+			return c
+		}
+	}
+	return unknownErrorCode
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+	"github.com/thansetan/git-sw/pkg/profile"
+)
+
+// exportedErrors returns the names of the exported Err* variables declared in
+// the non-test files of dir, prefixed with qualifier.
+func exportedErrors(t *testing.T, dir, qualifier string) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatalf("filepath.Glob() error = %v, want %v", err, nil)
+	}
+	var names []string
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatalf("parser.ParseFile(%s) error = %v, want %v", path, err, nil)
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					if name.IsExported() && strings.HasPrefix(name.Name, "Err") {
+						names = append(names, qualifier+name.Name)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+func TestErrorCodes(t *testing.T) {
+	// a new sentinel error has to be added here and to errorCodes
+	sentinels := map[string]error{
+		"ErrEmptyField":                     ErrEmptyField,
+		"ErrInvalidEmail":                   ErrInvalidEmail,
+		"ErrDuplicateProfile":               ErrDuplicateProfile,
+		"ErrInvalidAction":                  ErrInvalidAction,
+		"ErrNotImplemented":                 ErrNotImplemented,
+		"ErrEditDefaultConfig":              ErrEditDefaultConfig,
+		"ErrDeleteDefaultConfig":            ErrDeleteDefaultConfig,
+		"ErrDeleteAborted":                  ErrDeleteAborted,
+		"ErrInvalidPublicKeyExt":            ErrInvalidPublicKeyExt,
+		"ErrNotGitDirectory":                ErrNotGitDirectory,
+		"ErrNoBackups":                      ErrNoBackups,
+		"ErrBackupNotFound":                 ErrBackupNotFound,
+		"ErrInvalidScope":                   ErrInvalidScope,
+		"ErrScopeConflict":                  ErrScopeConflict,
+		"ErrWorktreeConfigDisabled":         ErrWorktreeConfigDisabled,
+		"ErrInvalidPrivateKeyExt":           ErrInvalidPrivateKeyExt,
+		"ErrSSHKeyMismatch":                 ErrSSHKeyMismatch,
+		"ErrInvalidCredentialURL":           ErrInvalidCredentialURL,
+		"ErrUnsupportedKeyPath":             ErrUnsupportedKeyPath,
+		"ErrRenameDefaultConfig":            ErrRenameDefaultConfig,
+		"ErrNothingToUndo":                  ErrNothingToUndo,
+		"ErrWhereDefaultConfig":             ErrWhereDefaultConfig,
+		"ErrProblemsLeft":                   ErrProblemsLeft,
+		"ErrNotDirectory":                   ErrNotDirectory,
+		"ErrInvalidRemotePattern":           ErrInvalidRemotePattern,
+		"ErrLockTimeout":                    ErrLockTimeout,
+		"ErrInvalidHookCommand":             ErrInvalidHookCommand,
+		"ErrHookExists":                     ErrHookExists,
+		"ErrHookNotManaged":                 ErrHookNotManaged,
+		"ErrHooksPathSet":                   ErrHooksPathSet,
+		"ErrIdentityMismatch":               ErrIdentityMismatch,
+		"ErrNoIdentity":                     ErrNoIdentity,
+		"ErrNoUpstream":                     ErrNoUpstream,
+		"ErrPushedCommits":                  ErrPushedCommits,
+		"ErrRewriteAborted":                 ErrRewriteAborted,
+		"ErrInvalidShell":                   ErrInvalidShell,
+		"ErrInvalidCompletionShell":         ErrInvalidCompletionShell,
+		"ErrShellNotInitialized":            ErrShellNotInitialized,
+		"ErrProfileArgConflict":             ErrProfileArgConflict,
+		"ErrUnknownSetting":                 ErrUnknownSetting,
+		"ErrRelativeStorageDir":             ErrRelativeStorageDir,
+		"ErrInvalidBool":                    ErrInvalidBool,
+		"ErrInvalidConfigCommand":           ErrInvalidConfigCommand,
+		"ErrInvalidFlag":                    ErrInvalidFlag,
+		"ErrInvalidOutput":                  ErrInvalidOutput,
+		"ErrMissingCommand":                 ErrMissingCommand,
+		"ErrInvalidRecord":                  ErrInvalidRecord,
+		"ErrInvalidRecords":                 ErrInvalidRecords,
+		"ErrFromConflict":                   ErrFromConflict,
+		"ErrUndoConflict":                   ErrUndoConflict,
+		"ErrSinceNotAncestor":               ErrSinceNotAncestor,
+		"ErrSSHAliasConflict":               ErrSSHAliasConflict,
+		"ErrMissingProfile":                 ErrMissingProfile,
+		"ErrMissingName":                    ErrMissingName,
+		"ErrMissingEmail":                   ErrMissingEmail,
+		"ErrProfileNotFound":                ErrProfileNotFound,
+		"ErrEditNoTUI":                      ErrEditNoTUI,
+		"ErrDeleteNoConfirm":                ErrDeleteNoConfirm,
+		"ErrInvalidKeyFormat":               ErrInvalidKeyFormat,
+		"ErrMissingSigningKey":              ErrMissingSigningKey,
+		"ErrMissingBackup":                  ErrMissingBackup,
+		"ErrMissingCredURL":                 ErrMissingCredURL,
+		"ErrMissingNewName":                 ErrMissingNewName,
+		"ErrInvalidSetting":                 ErrInvalidSetting,
+		"profile.ErrEmptyName":              profile.ErrEmptyName,
+		"profile.ErrDuplicateProfile":       profile.ErrDuplicateProfile,
+		"profile.ErrProfileNotFound":        profile.ErrProfileNotFound,
+		"profile.ErrDefaultProfile":         profile.ErrDefaultProfile,
+		"profile.ErrNotGitDirectory":        profile.ErrNotGitDirectory,
+		"profile.ErrWorktreeConfigDisabled": profile.ErrWorktreeConfigDisabled,
+		"profile.ErrRelativeStorageDir":     profile.ErrRelativeStorageDir,
+		"gitconfig.ErrInvalidKey":           gitconfig.ErrInvalidKey,
+		"gitconfig.ErrKeyNotFound":          gitconfig.ErrKeyNotFound,
+		"gitconfig.ErrInvalidValueType":     gitconfig.ErrInvalidValueType,
+		"gitconfig.ErrEmptyValue":           gitconfig.ErrEmptyValue,
+		"gitconfig.ErrInvalidSection":       gitconfig.ErrInvalidSection,
+		"gitconfig.ErrInvalidSubsection":    gitconfig.ErrInvalidSubsection,
+		"gitconfig.ErrInvalidVariableName":  gitconfig.ErrInvalidVariableName,
+		"gitconfig.ErrInvalidVariableValue": gitconfig.ErrInvalidVariableValue,
+		"gitconfig.ErrInvalidLine":          gitconfig.ErrInvalidLine,
+		"gitconfig.ErrLocked":               gitconfig.ErrLocked,
+		"gitconfig.ErrNotLocked":            gitconfig.ErrNotLocked,
+		"gitconfig.ErrNoRepository":         gitconfig.ErrNoRepository,
+		"gitconfig.ErrInvalidBool":          gitconfig.ErrInvalidBool,
+		"gitconfig.ErrUnencodableValue":     gitconfig.ErrUnencodableValue,
+	}
+	var names []string
+	names = append(names, exportedErrors(t, ".", "")...)
+	names = append(names, exportedErrors(t, filepath.Join("pkg", "profile"), "profile.")...)
+	names = append(names, exportedErrors(t, filepath.Join("pkg", "gitconfig"), "gitconfig.")...)
+	for _, name := range names {
+		err, ok := sentinels[name]
+		if !ok {
+			t.Errorf("%s is missing from the sentinels of this test", name)
+			continue
+		}
+		if code := lookupErrorCode(err); code == unknownErrorCode {
+			t.Errorf("lookupErrorCode(%s) = %s, want a code", name, code.Code)
+		}
+	}
+
+	statuses := make(map[string]int)
+	codes := make(map[int]string)
+	for _, c := range errorCodes {
+		if status, ok := statuses[c.Code]; ok && status != c.Status {
+			t.Errorf("%s has statuses %d and %d", c.Code, status, c.Status)
+		}
+		if code, ok := codes[c.Status]; ok && code != c.Code {
+			t.Errorf("status %d is both %s and %s", c.Status, code, c.Code)
+		}
+		statuses[c.Code], codes[c.Status] = c.Status, c.Code
+	}
+}
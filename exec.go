@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+// exitCodeError makes git-sw exit with the status of a child process,
+// without printing anything.
+type exitCodeError struct {
+	code int
+}
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// findProfile returns the profile named name, ignoring case.
+func findProfile(profiles []Profile, name string) (Profile, error) {
+	for _, p := range profiles {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+}
+
+// configEnv returns env with the variables of config added as
+// GIT_CONFIG_KEY_<n> and GIT_CONFIG_VALUE_<n>, after the ones already in env.
+// git reads them as if they were passed with -c, so they take precedence
+// over every config file.
+func configEnv(env []string, config *gitconfig.GitConfig) ([]string, error) {
+	var count int
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "GIT_CONFIG_COUNT="); ok {
+			n, err := parseConfigCount(v)
+			if err != nil {
+				return nil, err
+			}
+			count = n
+		}
+	}
+	env = slices.DeleteFunc(env, func(kv string) bool {
+		return strings.HasPrefix(kv, "GIT_CONFIG_COUNT=")
+	})
+	vars, err := configEnvVars(config, count)
+	if err != nil {
+		return nil, err
+	}
+	return append(env, vars...), nil
+}
+
+// parseConfigCount parses the value of GIT_CONFIG_COUNT, which is 0 if unset.
+func parseConfigCount(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid GIT_CONFIG_COUNT: %s", s)
+	}
+	return n, nil
+}
+
+// configEnvVars returns the variables of config as GIT_CONFIG_KEY_<n> and
+// GIT_CONFIG_VALUE_<n> pairs, numbered from first, followed by the new
+// GIT_CONFIG_COUNT.
+func configEnvVars(config *gitconfig.GitConfig, first int) ([]string, error) {
+	var (
+		vars  []string
+		count = first
+	)
+	for _, key := range config.Keys() {
+		values, err := config.GetAll(key.String())
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			vars = append(vars,
+				fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count, key),
+				fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count, value),
+			)
+			count++
+		}
+	}
+	return append(vars, fmt.Sprintf("GIT_CONFIG_COUNT=%d", count)), nil
+}
+
+// execWithProfile runs command with the config of profile applied through
+// the environment, forwarding its stdio. A non-zero exit status is returned
+// as an exitCodeError.
+func execWithProfile(profile Profile, command []string) error {
+	if len(command) == 0 {
+		return ErrMissingCommand
+	}
+	config, err := manager.LoadConfig(profile)
+	if err != nil {
+		return err
+	}
+	env, err := configEnv(os.Environ(), config)
+	if err != nil {
+		return err
+	}
+	env = append(env, activeProfileEnv+"="+profile.Name)
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// the child gets interrupts from the terminal too, let it decide what to do
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitCodeError{code: max(exitErr.ExitCode(), 1)}
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// scriptedUI is a UserInterface that gives the answers a test scripted
+// instead of prompting. Lists are rendered by the embedded UserInterface, a
+// TUI or NoTUI writing to the output of the test.
+type scriptedUI struct {
+	UserInterface
+	t *testing.T
+
+	created  []Profile // returned by CreateProfile, in order
+	selected []string  // names of the profiles returned by SelectProfile, in order
+	renamed  []string  // returned by RenameProfile, in order
+	confirms []bool    // returned by ConfirmDelete and Confirm, in order
+	edit     func(path string) error
+}
+
+// next removes the first answer from answers, failing the test if there's none.
+func next[T any](t *testing.T, answers *[]T, method string) T {
+	t.Helper()
+	if len(*answers) == 0 {
+		t.Fatalf("%s called without a scripted answer", method)
+	}
+	answer := (*answers)[0]
+	*answers = (*answers)[1:]
+	return answer
+}
+
+func (s *scriptedUI) CreateProfile() (Profile, error) {
+	return next(s.t, &s.created, "CreateProfile"), nil
+}
+
+func (s *scriptedUI) SelectProfile(profiles []Profile) (Profile, error) {
+	name := next(s.t, &s.selected, "SelectProfile")
+	for _, p := range profiles {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+	return Profile{}, ErrProfileNotFound
+}
+
+func (s *scriptedUI) RenameProfile(profile Profile, profiles []Profile) (string, error) {
+	newName := next(s.t, &s.renamed, "RenameProfile")
+	return newName, validateNewProfileName(newName, profile, profiles)
+}
+
+func (s *scriptedUI) ConfirmDelete() bool {
+	return next(s.t, &s.confirms, "ConfirmDelete")
+}
+
+func (s *scriptedUI) Confirm(label string) bool {
+	return next(s.t, &s.confirms, "Confirm")
+}
+
+func (s *scriptedUI) EditProfile(path string) error {
+	if s.edit == nil {
+		s.t.Fatalf("EditProfile called without a scripted edit")
+	}
+	return s.edit(path)
+}
+
+func (s *scriptedUI) SelectBackup(backups []Backup) (Backup, error) {
+	s.t.Fatalf("SelectBackup called without a scripted answer")
+	return Backup{}, nil
+}
+
+func (s *scriptedUI) PromptEmail(profile Profile) (string, error) {
+	s.t.Fatalf("PromptEmail called without a scripted answer")
+	return "", nil
+}
@@ -1,20 +1,23 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
 	"text/tabwriter"
 )
 
 var (
-	isGlobal      bool
-	allowedGlobal = map[Action]struct{}{
-		USE:    {},
-		EDIT:   {},
-		DELETE: {},
-	}
+	isGlobal    bool
+	scopeFlag   string
+	gitExecFlag bool
+	chdirFlags  []string
+	gitDirFlag  string
+	outputFlag  string
 
 	// Non-interactive mode flags
 	noTUI          bool
@@ -24,26 +27,60 @@ var (
 	signingKeyFlag string
 	keyFormatFlag  string
 	gpgProgramFlag string
-	yesFlag        bool
+	sshKeyFlag     string
+	sshHostFlag    string
+	backupFlag     string
+	newNameFlag    string
+	fixFlag        bool
+	remoteFlag     string
+	directoryFlag  string
+	sinceFlag      string
+	forceFlag      bool
+	fromFlag       string
+	partialFlag    bool
+
+	credentialURLFlag         string
+	credentialUsernameFlag    string
+	credentialHelperFlag      string
+	credentialUseHTTPPathFlag bool
+	yesFlag                   bool
 )
 
-func parseFlag() {
+// globalFlags are accepted by every command. All flags are still accepted
+// before the command, as they were before commands had their own flags.
+var globalFlags = []string{"C", "git-dir", "no-tui", "git-exec", "output"}
+
+// commandFlags is the flag set of the command being run, see parseCommandArgs.
+var commandFlags *flag.FlagSet
+
+// parseFlag parses the flags before the command. Errors are returned rather
+// than printed, so they can be reported in the requested output format.
+func parseFlag() error {
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	flag.CommandLine.SetOutput(io.Discard)
 	flag.Usage = func() {
-		sb := new(strings.Builder)
-		fmt.Fprintf(sb, "usage: %s [options] command\n", os.Args[0])
-		sb.WriteString("\nAvailable commands\n")
-		tw := tabwriter.NewWriter(sb, 0, 4, 1, ' ', 0)
-		for _, actionName := range actionString[1:] {
-			fmt.Fprintf(tw, "  %s\t\t%s\n", actionName, commands[getAction(actionName)].Description)
-		}
-		tw.Flush()
-		sb.WriteString("\nAvailable options:\n")
-		fmt.Fprint(flag.CommandLine.Output(), sb.String())
-		flag.PrintDefaults()
+		printUsage(os.Stderr)
 	}
+	defineFlags()
+	err := flag.CommandLine.Parse(os.Args[1:])
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return fmt.Errorf("%w: %w", ErrInvalidFlag, err)
+	}
+	return err
+}
 
+// defineFlags adds the flags of every command to the command line.
+func defineFlags() {
 	// Existing flags
-	flag.BoolVar(&isGlobal, "g", false, "Run the command globally (can only be used with the 'use', 'edit', and 'delete' commands).")
+	flag.BoolVar(&isGlobal, "g", false, "Run the command globally (can only be used with the 'use', 'edit', 'delete', and 'hook' commands).")
+	flag.StringVar(&scopeFlag, "scope", "", "Config scope to use: 'local', 'worktree', 'global', or 'system' (can only be used with the 'use', 'delete', and 'list' commands).")
+	flag.Func("C", "Run as if git-sw was started in `directory` instead of the current one, like git's -C (may be repeated).", func(dir string) error {
+		chdirFlags = append(chdirFlags, dir)
+		return nil
+	})
+	flag.StringVar(&gitDirFlag, "git-dir", "", "`Path` to the git directory of the repository, like git's --git-dir.")
+	flag.StringVar(&outputFlag, "output", "", "Output format of errors: 'text' or 'json' (default: the core.output setting).")
+	flag.BoolVar(&gitExecFlag, "git-exec", false, "Read and write git config through the git executable instead of editing the config files directly.")
 
 	// Non-interactive mode flags
 	flag.BoolVar(&noTUI, "no-tui", false, "Disable TUI prompts for automated/scripted usage.")
@@ -53,7 +90,132 @@ func parseFlag() {
 	flag.StringVar(&signingKeyFlag, "signing-key", "", "Signing key (GPG key ID, SSH key path, or X.509 certificate ID).")
 	flag.StringVar(&keyFormatFlag, "key-format", "", "Signing key format: 'openpgp', 'ssh', or 'x509' (default: openpgp if --signing-key is set).")
 	flag.StringVar(&gpgProgramFlag, "gpg-program", "", "GPG program to use (default: gpg). Only applicable for openpgp format.")
+	flag.StringVar(&sshKeyFlag, "ssh-key", "", "Path to an SSH private key used for git's SSH connections (written as core.sshCommand).")
+	flag.StringVar(&sshHostFlag, "ssh-host", "github.com", "SSH host to create per-profile aliases for (for ssh-setup).")
+	flag.StringVar(&remoteFlag, "remote", "", "Comma-separated host/org prefixes the profile is meant for, e.g. github.com/acme, written as sw.remote (for create).")
+	flag.StringVar(&directoryFlag, "directory", "", "Comma-separated directories whose repositories should use the profile, written as sw.directory (for create).")
+	flag.StringVar(&credentialURLFlag, "credential-url", "", "HTTPS URL the credential username applies to, e.g. https://github.com (for create/edit).")
+	flag.StringVar(&credentialUsernameFlag, "credential-username", "", "HTTPS username for --credential-url, written as credential.<url>.username (for create/edit).")
+	flag.StringVar(&credentialHelperFlag, "credential-helper", "", "Credential helper to use, written as credential.helper (for create/edit).")
+	flag.BoolVar(&credentialUseHTTPPathFlag, "credential-use-http-path", false, "Set credential.useHttpPath so credentials are scoped per repository path (for create/edit).")
+	flag.StringVar(&fromFlag, "from", "", "Create the profiles of the JSON Lines or CSV records in `file`, or '-' for stdin (for create).")
+	flag.BoolVar(&partialFlag, "partial", false, "With --from, create the valid records even if some are invalid, instead of none.")
+	flag.StringVar(&backupFlag, "backup", "", "ID of the backup to restore (for restore in --no-tui mode).")
+	flag.StringVar(&newNameFlag, "new-name", "", "New profile name (for rename in --no-tui mode).")
+	flag.BoolVar(&fixFlag, "fix", false, "Repair the problems found by doctor (asks before each fix, or fixes everything with --no-tui --yes).")
+	flag.StringVar(&sinceFlag, "since", "", "Rewrite the commits after this ref instead of the ones not on the upstream branch (for fix-author).")
+	flag.BoolVar(&forceFlag, "force", false, "Rewrite commits even if they have been pushed (for fix-author).")
 	flag.BoolVar(&yesFlag, "yes", false, "Confirm destructive operations without prompting (for --no-tui mode).")
+}
 
-	flag.Parse()
+// isFlagSet reports whether the flag with the given name was passed on the
+// command line, before or after the command.
+func isFlagSet(name string) bool {
+	var found bool
+	visit := func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	}
+	flag.Visit(visit)
+	if commandFlags != nil {
+		commandFlags.Visit(visit)
+	}
+	return found
+}
+
+// newFlagSet returns a flag set with the given flags of the command line.
+// They share their values, so they can be given in either place.
+func newFlagSet(name string, names ...string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	for _, name := range names {
+		f := flag.CommandLine.Lookup(name)
+		fs.Var(f.Value, f.Name, f.Usage)
+		fs.Lookup(name).DefValue = f.DefValue
+	}
+	return fs
+}
+
+// acceptsFlag reports whether command has the flag with the given name.
+func (c Command) acceptsFlag(name string) bool {
+	return slices.Contains(c.Flags, name) || slices.Contains(globalFlags, name)
+}
+
+// commandsWithFlag lists the commands that have the flag with the given
+// name, such as "'list' and 'use'", for error messages.
+func commandsWithFlag(name string) string {
+	var names []string
+	for _, actionName := range actionString[1:] {
+		if slices.Contains(commands[getAction(actionName)].Flags, name) {
+			names = append(names, fmt.Sprintf("'%s'", actionName))
+		}
+	}
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0]
+	case 2:
+		return names[0] + " and " + names[1]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+}
+
+// parseCommandArgs parses the arguments after the command with the flags of
+// action, which may come before, after or between the positional arguments.
+// Everything after "--" is positional, and the "--" is kept so commands that
+// run other commands can tell their own arguments apart.
+func parseCommandArgs(action Action, args []string) ([]string, error) {
+	commandFlags = newFlagSet(action.String(), append(commands[action].Flags, globalFlags...)...)
+	commandFlags.SetOutput(io.Discard)
+	var positional []string
+	for {
+		err := commandFlags.Parse(args)
+		if err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %w, see '%s help %s'", ErrInvalidFlag, err, progName, action)
+		}
+		rest := commandFlags.Args()
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			return append(append(positional, "--"), rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// printUsage prints the commands and the global flags.
+func printUsage(w io.Writer) {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "usage: %s [options] command [arguments]\n", progName)
+	sb.WriteString("\nAvailable commands\n")
+	tw := tabwriter.NewWriter(sb, 0, 4, 1, ' ', 0)
+	for _, actionName := range actionString[1:] {
+		fmt.Fprintf(tw, "  %s\t\t%s\n", actionName, commands[getAction(actionName)].Description)
+	}
+	tw.Flush()
+	sb.WriteString("\nAvailable options:\n")
+	fmt.Fprint(w, sb.String())
+	fs := newFlagSet(progName, globalFlags...)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fmt.Fprintf(w, "\nRun '%s help <command>' for the arguments and options of a command.\n", progName)
+}
+
+// printCommandUsage prints the arguments and flags of action.
+func printCommandUsage(w io.Writer, action Action) {
+	command := commands[action]
+	fmt.Fprintf(w, "usage: %s %s [options]", progName, action)
+	if command.Args != "" {
+		fmt.Fprintf(w, " %s", command.Args)
+	}
+	fmt.Fprintf(w, "\n\n%s\n\nAvailable options:\n", command.Description)
+	fs := newFlagSet(action.String(), append(command.Flags, globalFlags...)...)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
 }
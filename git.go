@@ -1,8 +1,10 @@
 package main
 
 import (
-	"fmt"
-	"os/exec"
+	"context"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/profile"
 )
 
 type GPGFormat string
@@ -15,56 +17,94 @@ const (
 
 var gpgFormat = []GPGFormat{OPENPGP, SSH, X509}
 
-func isGitDirectory() bool {
-	cmd := exec.Command("git", "rev-parse")
-	err := cmd.Run()
-	if err != nil && cmd.ProcessState.ExitCode() != 128 {
-		panic(err)
-	}
-	return cmd.ProcessState.ExitCode() == 0
+// Scope is the git config file a command reads from or writes to.
+// The zero value means no scope was requested, and each command picks
+// the scope git itself would use.
+type Scope = profile.Scope
+
+const (
+	LOCAL    = profile.Local
+	WORKTREE = profile.Worktree
+	GLOBAL   = profile.Global
+	SYSTEM   = profile.System
+)
+
+var scopes = profile.Scopes
+
+// getRepository returns the repository of the current directory. ok is false
+// if the current directory isn't part of any repository.
+func getRepository() (profile.Repository, bool, error) {
+	return manager.Repository(context.Background())
 }
 
-func unsetConfig(pattern string) error {
-	var cmd *exec.Cmd
-	if isGitDirectory() {
-		cmd = exec.Command("git", "config", "--unset", "include.path", pattern)
-	} else {
-		cmd = exec.Command("git", "config", "--global", "--unset", "include.path", pattern)
-	}
-	gitOutput, err := cmd.CombinedOutput()
-	if err != nil && cmd.ProcessState.ExitCode() != 5 { // try to unset an option that does not exist will give exit 5
-		fmt.Printf("git: %s", string(gitOutput))
-		return err
-	}
-	return nil
+// isGitDirectory reports whether the current directory is inside a work tree or
+// a bare repository, i.e. a place where a repository-scoped config makes sense.
+// The .git directory of a non-bare repository doesn't count.
+func isGitDirectory() (bool, error) {
+	return manager.InRepository(context.Background())
 }
 
-func applyConfig(configPath string, isGlobal bool) error {
-	var cmd *exec.Cmd
+// getScope returns the scope requested through -g or --scope.
+func getScope() (Scope, error) {
 	if isGlobal {
-		cmd = exec.Command("git", "config", "--global", "--replace-all", "include.path", configPath, fmt.Sprintf("%s.*gitconfig$", saveDirName))
-	} else {
-		cmd = exec.Command("git", "config", "--replace-all", "include.path", configPath, fmt.Sprintf("%s.*gitconfig$", saveDirName))
+		if scopeFlag != "" && Scope(scopeFlag) != GLOBAL {
+			return "", ErrScopeConflict
+		}
+		return GLOBAL, nil
+	}
+	if scopeFlag == "" {
+		return "", nil
 	}
-	gitOutput, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Printf("git: %s", string(gitOutput))
-		return err
+	for _, s := range scopes {
+		if Scope(strings.ToLower(scopeFlag)) == s {
+			return s, nil
+		}
 	}
-	return nil
+	return "", ErrInvalidScope
 }
 
-func getCurrentConfig() (string, error) {
-	var cmd *exec.Cmd
-	if !isGlobal && isGitDirectory() {
-		cmd = exec.Command("git", "config", "--worktree", "--get", "include.path", fmt.Sprintf("%s.*gitconfig$", saveDirName))
-	} else {
-		cmd = exec.Command("git", "config", "--global", "--get", "include.path", fmt.Sprintf("%s.*gitconfig$", saveDirName))
-	}
-	gitOutput, err := cmd.CombinedOutput()
-	if err != nil && cmd.ProcessState.ExitCode() != 1 {
-		fmt.Printf("git: %s", string(gitOutput))
-		return "", err
-	}
-	return string(gitOutput), nil
+// writeScopes returns the scopes a profile include has to be written to or
+// removed from, see profile.Manager.WriteScopes.
+func writeScopes(scope Scope) ([]Scope, error) {
+	return manager.WriteScopes(context.Background(), scope)
+}
+
+// scopeFilePath returns the path of the config file of the given scope. It's
+// empty if the system config is disabled.
+func scopeFilePath(scope Scope) (string, error) {
+	return manager.ScopePath(context.Background(), scope)
+}
+
+// unsetConfigFile removes the includes matching pattern from the config file
+// at path.
+func unsetConfigFile(pattern string, path string) error {
+	return manager.UnsetIncludesFile(context.Background(), pattern, path)
+}
+
+// replaceIncludeFile replaces the includes matching pattern in the config file
+// at path with one of configPath.
+func replaceIncludeFile(configPath, pattern, path string) error {
+	return manager.ReplaceIncludesFile(context.Background(), configPath, pattern, path)
+}
+
+// getAllFile returns the values of key in the config file at path.
+func getAllFile(key, path string) ([]string, error) {
+	return manager.GetAll(context.Background(), key, path)
+}
+
+// setFile sets key to value in the config file at path, replacing all of its
+// values.
+func setFile(key, value, path string) error {
+	return manager.Set(context.Background(), key, value, path)
+}
+
+// setAllFile sets key to values in the config file at path, replacing all of
+// its values.
+func setAllFile(key string, values []string, path string) error {
+	return manager.SetAll(context.Background(), key, values, path)
+}
+
+// unsetFile removes all values of key from the config file at path.
+func unsetFile(key, path string) error {
+	return manager.Unset(context.Background(), key, path)
 }
@@ -5,9 +5,7 @@ go 1.22.4
 require (
 	github.com/manifoldco/promptui v0.9.0
 	golang.org/x/crypto v0.24.0
+	golang.org/x/sys v0.21.0
 )
 
-require (
-	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e // indirect
-	golang.org/x/sys v0.21.0 // indirect
-)
+require github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e // indirect
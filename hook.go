@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+const (
+	hooksDirName = "hooks"
+	hookMarker   = "# managed by git-sw"
+)
+
+// chainedHooks are the client-side hooks a global core.hooksPath hides, so
+// the global hooks run the ones of the repository instead.
+var chainedHooks = []string{
+	"applypatch-msg", "pre-applypatch", "post-applypatch", "pre-commit",
+	"pre-merge-commit", "prepare-commit-msg", "commit-msg", "post-commit",
+	"pre-rebase", "post-checkout", "post-merge", "pre-push", "pre-auto-gc",
+	"post-rewrite",
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// hookScript returns the content of a hook. The pre-commit hook runs
+// 'git-sw check'; with chain, the hook of the repository runs afterwards.
+func hookScript(name string, chain bool) (string, error) {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "#!/bin/sh\n%s\n", hookMarker)
+	if name == "pre-commit" {
+		executable, err := os.Executable()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(sb, "%s check || exit 1\n", shellQuote(filepath.ToSlash(executable)))
+	}
+	if chain {
+		fmt.Fprintf(sb, "hook=\"$(git rev-parse --git-common-dir)/hooks/%s\"\n", name)
+		sb.WriteString("if [ -x \"$hook\" ]; then\n\texec \"$hook\" \"$@\"\nfi\n")
+	}
+	return sb.String(), nil
+}
+
+// isManagedHook reports whether the hook at path was written by git-sw.
+// A hook that doesn't exist counts as managed, as it can be written.
+func isManagedHook(path string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return true, nil
+		}
+		return false, err
+	}
+	return strings.Contains(string(content), hookMarker), nil
+}
+
+// repoHookPath returns the path of the pre-commit hook of the current repository.
+func repoHookPath() (string, error) {
+	repo, ok, err := getRepository()
+	if err != nil {
+		return "", err
+	}
+	if !ok || !(repo.InsideWorkTree || repo.IsBare) {
+		return "", ErrNotGitDirectory
+	}
+	return filepath.Join(repo.CommonDir, hooksDirName, "pre-commit"), nil
+}
+
+// installRepoHook writes the pre-commit hook of the current repository.
+func installRepoHook(entry *JournalEntry) (string, error) {
+	path, err := repoHookPath()
+	if err != nil {
+		return "", err
+	}
+	managed, err := isManagedHook(path)
+	if err != nil {
+		return "", err
+	}
+	if !managed {
+		return "", fmt.Errorf("%w: %s", ErrHookExists, path)
+	}
+	script, err := hookScript("pre-commit", false)
+	if err != nil {
+		return "", err
+	}
+	err = entry.snapshot(path)
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return "", err
+	}
+	return path, gitconfig.WriteFileAtomic(path, []byte(script), 0o755)
+}
+
+// installGlobalHooks writes the hooks to saveDirPath and points the global
+// core.hooksPath at them.
+func installGlobalHooks(entry *JournalEntry) (string, error) {
+	dir := filepath.Join(saveDirPath, hooksDirName)
+	globalConfigPath, err := gitconfig.GlobalConfigPath()
+	if err != nil {
+		return "", err
+	}
+	hooksPaths, err := getAllFile("core.hooksPath", globalConfigPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	for _, hooksPath := range hooksPaths {
+		if filepath.Clean(filepath.FromSlash(hooksPath)) != dir {
+			return "", fmt.Errorf("%w: %s", ErrHooksPathSet, hooksPath)
+		}
+	}
+
+	err = entry.snapshot(globalConfigPath)
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range chainedHooks {
+		script, err := hookScript(name, true)
+		if err != nil {
+			return "", err
+		}
+		path := filepath.Join(dir, name)
+		err = entry.snapshot(path)
+		if err != nil {
+			return "", err
+		}
+		err = gitconfig.WriteFileAtomic(path, []byte(script), 0o755)
+		if err != nil {
+			return "", err
+		}
+	}
+	return dir, setFile("core.hooksPath", filepath.ToSlash(dir), globalConfigPath)
+}
+
+// uninstallRepoHook removes the pre-commit hook of the current repository if
+// git-sw wrote it.
+func uninstallRepoHook(entry *JournalEntry) (string, error) {
+	path, err := repoHookPath()
+	if err != nil {
+		return "", err
+	}
+	managed, err := isManagedHook(path)
+	if err != nil {
+		return "", err
+	}
+	if !managed {
+		return "", fmt.Errorf("%w: %s", ErrHookNotManaged, path)
+	}
+	err = entry.snapshot(path)
+	if err != nil {
+		return "", err
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	return path, nil
+}
+
+// uninstallGlobalHooks removes the global hooks and the core.hooksPath
+// pointing at them.
+func uninstallGlobalHooks(entry *JournalEntry) (string, error) {
+	dir := filepath.Join(saveDirPath, hooksDirName)
+	globalConfigPath, err := gitconfig.GlobalConfigPath()
+	if err != nil {
+		return "", err
+	}
+	hooksPaths, err := getAllFile("core.hooksPath", globalConfigPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	err = entry.snapshot(globalConfigPath)
+	if err != nil {
+		return "", err
+	}
+	for _, hooksPath := range hooksPaths {
+		if filepath.Clean(filepath.FromSlash(hooksPath)) == dir {
+			err = unsetFile("core.hooksPath", globalConfigPath)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	for _, name := range chainedHooks {
+		path := filepath.Join(dir, name)
+		err = entry.snapshot(path)
+		if err != nil {
+			return "", err
+		}
+		err = os.Remove(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	os.Remove(dir) // only succeeds if nothing else is in there
+	return dir, nil
+}
+
+// identityEmail returns the email of an identity reported by 'git var', such
+// as GIT_COMMITTER_IDENT ("Name <email> timestamp tz").
+func identityEmail(variable string) (string, error) {
+	cmd := manager.Command(context.Background(), "var", variable)
+	gitOutput, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("%w: %s", ErrNoIdentity, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	_, rest, ok := strings.Cut(string(gitOutput), "<")
+	email, _, ok2 := strings.Cut(rest, ">")
+	if !ok || !ok2 {
+		return "", fmt.Errorf("unexpected git var output: %q", gitOutput)
+	}
+	return email, nil
+}
+
+// repoRemotes returns the remote URLs of the current repository.
+func repoRemotes() ([]string, error) {
+	cmd := manager.Command(context.Background(), "config", "--get-regexp", `^remote\..*\.url$`)
+	gitOutput, err := cmd.Output()
+	if err != nil {
+		if cmd.ProcessState != nil && cmd.ProcessState.ExitCode() == 1 { // no remotes
+			return nil, nil
+		}
+		return nil, err
+	}
+	var remotes []string
+	for _, line := range strings.Split(strings.TrimSpace(string(gitOutput)), "\n") {
+		if _, remote, ok := strings.Cut(line, " "); ok {
+			remotes = append(remotes, remote)
+		}
+	}
+	return remotes, nil
+}
+
+// checkIdentity verifies that the author and committer of the next commit
+// in the current repository use the email of the profile bound to the
+// repository's remotes or directory. Repositories without a bound profile
+// always pass.
+func checkIdentity(profiles []Profile) error {
+	repo, ok, err := getRepository()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotGitDirectory
+	}
+	remotes, err := repoRemotes()
+	if err != nil {
+		return err
+	}
+	bindings, err := loadBindings(profiles)
+	if err != nil {
+		return err
+	}
+	dir := repo.WorkTree
+	if dir == "" {
+		dir = repo.GitDir
+	}
+	expected, ok := expectedProfile(bindings, remotes, dir)
+	if !ok {
+		return nil
+	}
+	config, err := manager.LoadConfig(expected.Profile)
+	if err != nil {
+		return err
+	}
+	expectedEmail, err := config.Get("user.email")
+	if err != nil {
+		return fmt.Errorf("profile \"%s\" has no user.email: %w", expected.Profile.Name, err)
+	}
+
+	for _, variable := range []string{"GIT_AUTHOR_IDENT", "GIT_COMMITTER_IDENT"} {
+		email, err := identityEmail(variable)
+		if err == nil && strings.EqualFold(email, expectedEmail.String()) {
+			continue
+		}
+		if err == nil {
+			err = fmt.Errorf("%w: committing as %s", ErrIdentityMismatch, email)
+		}
+		return fmt.Errorf("%w, but this repository expects profile \"%s\" (%s)\nrun '%s use \"%s\"' to switch, or commit with --no-verify to skip this check",
+			err, expected.Profile.Name, expectedEmail, progName, expected.Profile.Name)
+	}
+	return nil
+}
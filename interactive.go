@@ -1,18 +1,25 @@
 package main
 
+import "io"
+
 // TUI implements UserInterface using the existing promptui-based interactive interface.
-type TUI struct{}
+type TUI struct {
+	Out io.Writer // where lists are written
+}
 
 func (t *TUI) CreateProfile() (Profile, error) {
 	return displayCreateForm()
 }
 
 func (t *TUI) SelectProfile(profiles []Profile) (Profile, error) {
+	if profileFlag != "" {
+		return findProfile(profiles, profileFlag)
+	}
 	return displayProfileSelector(profiles)
 }
 
 func (t *TUI) ListProfiles(profiles []Profile) error {
-	return displayProfileList(profiles)
+	return displayProfileList(t.Out, profiles)
 }
 
 func (t *TUI) ConfirmDelete() bool {
@@ -22,3 +29,31 @@ func (t *TUI) ConfirmDelete() bool {
 func (t *TUI) EditProfile(path string) error {
 	return openTextEditor(path)
 }
+
+func (t *TUI) SelectBackup(backups []Backup) (Backup, error) {
+	return displayBackupSelector(backups)
+}
+
+func (t *TUI) RenameProfile(profile Profile, profiles []Profile) (string, error) {
+	return displayRenameForm(profile, profiles)
+}
+
+func (t *TUI) ListHistory(entries []JournalEntry) error {
+	return displayHistory(t.Out, entries)
+}
+
+func (t *TUI) ListRepos(profile Profile, uses []RepoUse) error {
+	return displayRepoList(t.Out, profile, uses)
+}
+
+func (t *TUI) ListScan(results []ScanResult) error {
+	return displayScanResults(t.Out, results)
+}
+
+func (t *TUI) Confirm(label string) bool {
+	return displayConfirmation(label)
+}
+
+func (t *TUI) PromptEmail(profile Profile) (string, error) {
+	return displayEmailPrompt(profile)
+}
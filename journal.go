@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+const journalFileName = "journal.jsonl"
+
+// JournalFile is the content a file had before a command changed it.
+type JournalFile struct {
+	Path    string            `json:"path"`
+	Existed bool              `json:"existed"`
+	Mode    fs.FileMode       `json:"mode,omitempty"`
+	Content []byte            `json:"content,omitempty"`
+	After   *JournalFileState `json:"after,omitempty"` // what the command left, nil in older entries
+}
+
+// JournalFileState is the content of a file at some point, if it existed.
+type JournalFileState struct {
+	Existed bool   `json:"existed"`
+	Content []byte `json:"content,omitempty"`
+}
+
+func readJournalFileState(path string) (JournalFileState, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return JournalFileState{}, nil
+	}
+	return JournalFileState{Existed: err == nil, Content: content}, err
+}
+
+func (s JournalFileState) equal(other JournalFileState) bool {
+	return s.Existed == other.Existed && bytes.Equal(s.Content, other.Content)
+}
+
+// JournalEntry records a mutating command, with everything needed to undo it.
+type JournalEntry struct {
+	ID      int           `json:"id"`
+	Time    time.Time     `json:"time"`
+	Command string        `json:"command"`
+	Profile string        `json:"profile,omitempty"`
+	Files   []JournalFile `json:"files"`
+	Repos   []string      `json:"repos,omitempty"`
+	Undoes  int           `json:"undoes,omitempty"` // ID of the entry reverted by an undo entry
+	Backup  string        `json:"backup,omitempty"` // backup made by a delete
+}
+
+func journalPath() string {
+	return filepath.Join(saveDirPath, journalFileName)
+}
+
+func newJournalEntry(action Action, profileName string) *JournalEntry {
+	return &JournalEntry{
+		Time:    time.Now(),
+		Command: action.String(),
+		Profile: profileName,
+	}
+}
+
+// snapshot records the current content of the files at paths. It has to be
+// called before the files are changed. Paths already recorded are skipped,
+// so the oldest content is kept.
+func (e *JournalEntry) snapshot(paths ...string) error {
+	for _, path := range paths {
+		if slices.ContainsFunc(e.Files, func(f JournalFile) bool { return f.Path == path }) {
+			continue
+		}
+		file := JournalFile{Path: path}
+		info, err := os.Stat(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err == nil {
+			file.Existed, file.Mode = true, info.Mode().Perm()
+			file.Content, err = os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+		}
+		e.Files = append(e.Files, file)
+	}
+	return nil
+}
+
+// snapshotScope records the config file of scope and the repository it belongs to.
+func (e *JournalEntry) snapshotScope(scope Scope) error {
+	path, err := scopeFilePath(scope)
+	if err != nil || path == "" {
+		return err
+	}
+	if scope.IsRepoScope() {
+		repo, ok, err := getRepository()
+		if err != nil {
+			return err
+		}
+		if dir := cmp.Or(repo.WorkTree, repo.GitDir); ok && !slices.Contains(e.Repos, dir) {
+			e.Repos = append(e.Repos, dir)
+		}
+	}
+	return e.snapshot(path)
+}
+
+// snapshotProfile records the files of a profile.
+func (e *JournalEntry) snapshotProfile(dirPath string) error {
+	return e.snapshot(filepath.Join(dirPath, ".gitconfig"), filepath.Join(dirPath, "profile"))
+}
+
+// changed reports whether any of the recorded files differs from its snapshot.
+func (e *JournalEntry) changed() bool {
+	for _, file := range e.Files {
+		content, err := os.ReadFile(file.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			if file.Existed {
+				return true
+			}
+			continue
+		}
+		if err != nil || !file.Existed || !bytes.Equal(content, file.Content) {
+			return true
+		}
+	}
+	return false
+}
+
+// record saves the entry, unless the command didn't change any of its files.
+func (e *JournalEntry) record() error {
+	if !e.changed() {
+		return nil
+	}
+	return e.save()
+}
+
+// save appends the entry to the journal, along with the content the command
+// left in its files.
+func (e *JournalEntry) save() error {
+	for i := range e.Files {
+		after, err := readJournalFileState(e.Files[i].Path)
+		if err != nil {
+			return err
+		}
+		e.Files[i].After = &after
+	}
+	entries, err := getJournal()
+	if err != nil {
+		return err
+	}
+	e.ID = 1
+	if len(entries) > 0 {
+		e.ID = entries[len(entries)-1].ID + 1
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(saveDirPath, 0o744)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(journalPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// getJournal returns all journal entries, oldest first.
+func getJournal() ([]JournalEntry, error) {
+	f, err := os.Open(journalPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry JournalEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			continue // a line cut short by a crash, skip it
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// undoneEntries returns the IDs of the entries that have been undone.
+func undoneEntries(entries []JournalEntry) map[int]bool {
+	undone := make(map[int]bool)
+	for _, entry := range entries {
+		if entry.Undoes != 0 {
+			undone[entry.Undoes] = true
+		}
+	}
+	return undone
+}
+
+// lastUndoableEntry returns the newest entry that is neither an undo nor has
+// been undone already.
+func lastUndoableEntry(entries []JournalEntry) (JournalEntry, error) {
+	undone := undoneEntries(entries)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Undoes == 0 && !undone[entries[i].ID] {
+			return entries[i], nil
+		}
+	}
+	return JournalEntry{}, ErrNothingToUndo
+}
+
+// revert writes back the content the files of entry had before the command
+// ran, removing the files that didn't exist. A file that was changed since
+// only has the profile includes the command changed reverted, and if the
+// command changed more than that, nothing is reverted at all.
+func (e JournalEntry) revert() error {
+	includesOnly := make([]bool, len(e.Files))
+	for i, file := range e.Files {
+		if file.After == nil {
+			continue
+		}
+		current, err := readJournalFileState(file.Path)
+		if err != nil {
+			return err
+		}
+		if current.equal(*file.After) {
+			continue
+		}
+		if _, ok := file.includesChange(); !ok {
+			return fmt.Errorf("%w: %s", ErrUndoConflict, file.Path)
+		}
+		includesOnly[i] = true
+	}
+
+	for i := len(e.Files) - 1; i >= 0; i-- {
+		file := e.Files[i]
+		if includesOnly[i] {
+			err := file.revertIncludes()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !file.Existed {
+			err := os.Remove(file.Path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			os.Remove(filepath.Dir(file.Path)) // only succeeds if the directory is empty
+			continue
+		}
+		err := os.MkdirAll(filepath.Dir(file.Path), 0o744)
+		if err != nil {
+			return err
+		}
+		err = gitconfig.WriteFileAtomic(file.Path, file.Content, file.Mode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// includesChange reports whether the command changed nothing in the file but
+// its profile includes, and returns the include the file had before, if any.
+func (f JournalFile) includesChange() (string, bool) {
+	before, rest, err := splitProfileIncludes(f.Path, f.Content)
+	if err != nil || len(before) > 1 {
+		return "", false
+	}
+	_, afterRest, err := splitProfileIncludes(f.Path, f.After.Content)
+	if err != nil || !bytes.Equal(rest, afterRest) {
+		return "", false
+	}
+	if len(before) == 0 {
+		return "", true
+	}
+	return before[0], true
+}
+
+// revertIncludes puts back the profile include the file had before the
+// command ran, keeping the rest of its current content.
+func (f JournalFile) revertIncludes() error {
+	include, _ := f.includesChange()
+	pattern := manager.IncludePattern().String()
+	if include == "" {
+		err := manager.UnsetIncludesFile(context.Background(), pattern, f.Path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return manager.ReplaceIncludesFile(context.Background(), include, pattern, f.Path)
+}
+
+// splitProfileIncludes parses content as the config file at path and returns
+// its profile includes and the content without them.
+func splitProfileIncludes(path string, content []byte) ([]string, []byte, error) {
+	f, err := gitconfig.ParseFile(path, content)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err := f.GetAll("include.path")
+	if err != nil && !errors.Is(err, gitconfig.ErrKeyNotFound) {
+		return nil, nil, err
+	}
+	var includes []string
+	for _, value := range values {
+		if manager.IncludePattern().MatchString(value.String()) {
+			includes = append(includes, value.String())
+		}
+	}
+	_, err = f.UnsetAll("include.path", manager.IncludePattern())
+	if err != nil {
+		return nil, nil, err
+	}
+	return includes, f.Bytes(), nil
+}
+
+// snapshotRepoUse records a config file from the registry and its repository.
+func (e *JournalEntry) snapshotRepoUse(use RepoUse) error {
+	if use.Repo != "" && !slices.Contains(e.Repos, use.Repo) {
+		e.Repos = append(e.Repos, use.Repo)
+	}
+	return e.snapshot(use.Config)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	lockFileName   = "lock"
+	lockTimeout    = 10 * time.Second
+	lockRetryDelay = 50 * time.Millisecond
+)
+
+// lockFile is an advisory lock on saveDirPath, which makes git-sw processes
+// take turns at modifying profiles and config files. It's an OS lock on the
+// open lock file, so the kernel releases it when its owner dies, and the
+// file itself is never removed: a process that removed it could leave
+// another one locking a file nobody else opens anymore.
+type lockFile struct {
+	f *os.File
+}
+
+// heldLock is the lock held by this process, if any.
+var heldLock *lockFile
+
+// acquireLock takes the lock on saveDirPath, waiting up to timeout for
+// another process to release it.
+func acquireLock(timeout time.Duration) (*lockFile, error) {
+	err := os.MkdirAll(saveDirPath, 0o744)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(saveDirPath, lockFileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err = tryLockFile(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLocked) {
+			f.Close()
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w (held by %s)", ErrLockTimeout, lockOwner(path))
+		}
+		time.Sleep(lockRetryDelay)
+	}
+
+	// the owner is only there to be shown to the processes that wait
+	hostname, _ := os.Hostname()
+	err = f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt([]byte(fmt.Sprintf("%d %s\n", os.Getpid(), cmp.Or(hostname, "unknown"))), 0)
+	}
+	if err != nil {
+		unlockFile(f)
+		f.Close()
+		return nil, err
+	}
+	return &lockFile{f: f}, nil
+}
+
+// lockOwner returns a description of the owner of the lock at path.
+func lockOwner(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "unknown process"
+	}
+	pid, hostname, ok := strings.Cut(strings.TrimSpace(string(content)), " ")
+	if !ok {
+		return "unknown process"
+	}
+	return fmt.Sprintf("pid %s on %s", pid, hostname)
+}
+
+// release releases the lock.
+func (l *lockFile) release() error {
+	err := l.f.Truncate(0)
+	if unlockErr := unlockFile(l.f); err == nil {
+		err = unlockErr
+	}
+	if closeErr := l.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lockHolderEnv makes the test binary a process that takes the lock in the
+// directory it names, reports it, and waits to be killed.
+const lockHolderEnv = "GIT_SW_TEST_LOCK_HOLDER"
+
+func TestLockHolder(t *testing.T) {
+	dir := os.Getenv(lockHolderEnv)
+	if dir == "" {
+		t.Skip("only run as a helper process")
+	}
+	saveDirPath = dir
+	_, err := acquireLock(lockRetryDelay)
+	if err != nil {
+		t.Fatalf("acquireLock() error = %v, want %v", err, nil)
+	}
+	fmt.Println("locked")
+	time.Sleep(time.Minute)
+}
+
+func setTestSaveDir(t *testing.T) {
+	t.Helper()
+	oldSaveDirPath := saveDirPath
+	t.Cleanup(func() { saveDirPath = oldSaveDirPath })
+	saveDirPath = t.TempDir()
+}
+
+func TestAcquireLock(t *testing.T) {
+	setTestSaveDir(t)
+
+	l, err := acquireLock(lockRetryDelay)
+	if err != nil {
+		t.Fatalf("acquireLock() error = %v, want %v", err, nil)
+	}
+	_, err = acquireLock(lockRetryDelay)
+	if want := fmt.Sprintf("pid %d", os.Getpid()); !errors.Is(err, ErrLockTimeout) || !strings.Contains(err.Error(), want) {
+		t.Errorf("second acquireLock() error = %v, want %v held by %s", err, ErrLockTimeout, want)
+	}
+	err = l.release()
+	if err != nil {
+		t.Fatalf("lockFile.release() error = %v, want %v", err, nil)
+	}
+	l, err = acquireLock(lockRetryDelay)
+	if err != nil {
+		t.Fatalf("acquireLock() after release error = %v, want %v", err, nil)
+	}
+	l.release()
+}
+
+func TestAcquireLock_OwnerDied(t *testing.T) {
+	setTestSaveDir(t)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockHolder$")
+	cmd.Env = append(os.Environ(), lockHolderEnv+"="+saveDirPath)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("cmd.StdoutPipe() error = %v, want %v", err, nil)
+	}
+	err = cmd.Start()
+	if err != nil {
+		t.Fatalf("cmd.Start() error = %v, want %v", err, nil)
+	}
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || line != "locked\n" {
+		cmd.Process.Kill()
+		t.Fatalf("helper process output = (%q, %v), want %q", line, err, "locked\n")
+	}
+
+	_, err = acquireLock(lockRetryDelay)
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("acquireLock() while held error = %v, want %v", err, ErrLockTimeout)
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	l, err := acquireLock(lockTimeout)
+	if err != nil {
+		t.Fatalf("acquireLock() after the owner died error = %v, want %v", err, nil)
+	}
+	l.release()
+	if _, err := os.Stat(filepath.Join(saveDirPath, lockFileName)); err != nil {
+		t.Errorf("os.Stat() error = %v, want the lock file kept", err)
+	}
+}
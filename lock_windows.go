@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// errLocked is returned by tryLockFile if another process holds the lock.
+var errLocked = windows.ERROR_LOCK_VIOLATION
+
+// lockOffset is where the locked byte is. Windows keeps other processes
+// from reading a locked range, so it's far past the owner written at the
+// start of the file.
+const lockOffset = 1 << 30
+
+// tryLockFile takes an exclusive lock on f without waiting.
+func tryLockFile(f *os.File) error {
+	ol := windows.Overlapped{Offset: lockOffset}
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+}
+
+// unlockFile releases the lock on f.
+func unlockFile(f *os.File) error {
+	ol := windows.Overlapped{Offset: lockOffset}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
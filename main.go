@@ -1,74 +1,168 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/thansetan/git-sw/pkg/profile"
 )
 
 const (
-	defaultConfigName = "default"
+	defaultConfigName = profile.DefaultName
 	saveDirName       = "git-sw"
 )
 
 var (
 	userHomeDir, saveDirPath string
 	profiles                 []Profile
+	// manager does the work of the commands, which are a layer over it.
+	manager *profile.Manager
+	// progName is how git-sw is invoked in messages. Installed as git-sw,
+	// it's "git sw", as git runs it for that too.
+	progName = getProgName()
 )
 
+func getProgName() string {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	if command, ok := strings.CutPrefix(name, "git-"); ok {
+		return "git " + command
+	}
+	return name
+}
+
 func main() {
-	var err error
-	parseFlag()
+	err := parseFlag()
+	if errors.Is(err, flag.ErrHelp) {
+		return // printed by flag.Usage
+	}
+	if err != nil {
+		errorAndExit(err)
+	}
+	if outputFlag != "" {
+		err = validateOutput(outputFlag)
+		if err != nil {
+			errorAndExit(err)
+		}
+	}
+	if !colorEnabled {
+		disablePromptColors()
+	}
 	if len(os.Args) == 1 {
 		flag.Usage()
 		return
 	}
 
-	// Initialize AppState with appropriate UI
-	app := NewAppState(noTUI)
-
 	cmd := flag.Arg(0)
 	action := getAction(strings.ToLower(cmd))
 	if !action.IsValid() {
-		fmt.Println(formatError(fmt.Errorf("invalid command = %s", cmd)))
+		err = fmt.Errorf("%w: %s", ErrInvalidAction, cmd)
+		if outputFormat() == jsonOutput {
+			errorAndExit(err)
+		}
+		fmt.Println(formatError(err))
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(lookupErrorCode(err).Status)
 	}
-	if _, ok := allowedGlobal[action]; isGlobal && !ok {
-		errorAndExit(errors.New("flag -g can only be used with the 'use', 'edit', and 'delete' commands"))
+	command, ok := commands[action]
+	if !ok {
+		errorAndExit(ErrNotImplemented)
+	}
+	args, err := parseCommandArgs(action, flag.Args()[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		printCommandUsage(os.Stdout, action)
+		return
 	}
-
-	userHomeDir, err = os.UserHomeDir()
 	if err != nil {
 		errorAndExit(err)
 	}
-	userConfigDir, err := os.UserConfigDir()
+	args, err = takeProfileArg(command, args)
 	if err != nil {
 		errorAndExit(err)
 	}
-	saveDirPath = filepath.Join(userConfigDir, saveDirName)
-	err = os.MkdirAll(saveDirPath, 0o744)
+	err = applyGitOptions()
 	if err != nil {
 		errorAndExit(err)
 	}
-	err = copyDefault()
+	app, err := NewAppState()
 	if err != nil {
 		errorAndExit(err)
 	}
-	profiles, err = getProfiles(saveDirPath)
-	if err != nil {
+	app.Args = args
+	err = loadSettings(app)
+	if err != nil && action != CONFIG { // config can still fix the bad setting
 		errorAndExit(err)
 	}
+	if !isFlagSet("no-tui") {
+		noTUI = !getBoolSetting(tuiSettingKey)
+	}
+	app.UI = newUI(noTUI, app.Out)
 
-	command, ok := commands[action]
-	if !ok {
-		errorAndExit(ErrNotImplemented)
+	if isGlobal && !command.acceptsFlag("g") {
+		errorAndExit(fmt.Errorf("flag -g can only be used with the %s commands", commandsWithFlag("g")))
+	}
+	if scopeFlag != "" && !command.acceptsFlag("scope") {
+		errorAndExit(fmt.Errorf("flag --scope can only be used with the %s commands", commandsWithFlag("scope")))
+	}
+	app.Scope, err = getScope()
+	if err != nil {
+		errorAndExit(err)
 	}
-	err = command.Func(app)
+
+	err = run(app, command)
 	if err != nil {
 		errorAndExit(err)
 	}
 }
+
+// takeProfileArg moves the profile given as the first positional argument of
+// command to profileFlag, and returns the other arguments.
+func takeProfileArg(command Command, args []string) ([]string, error) {
+	if !command.ProfileArg || len(args) == 0 || args[0] == "--" {
+		return args, nil
+	}
+	if profileFlag != "" && profileFlag != args[0] {
+		return nil, ErrProfileArgConflict
+	}
+	profileFlag = args[0]
+	return args[1:], nil
+}
+
+// run runs command for app, whose settings have been loaded. The state the
+// commands share is set up from app first.
+func run(app *AppState, command Command) error {
+	userHomeDir = app.HomeDir
+	var err error
+	saveDirPath, err = storageDir()
+	if err != nil {
+		return err
+	}
+	manager, err = profile.New(profile.Options{StorageDir: saveDirPath, GitExec: gitExecFlag, Git: app.Git, Stderr: app.Out})
+	if err != nil {
+		return err
+	}
+
+	if !command.ReadOnly {
+		// held from reading the profiles until the command is done
+		heldLock, err = acquireLock(lockTimeout)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			heldLock.release()
+			heldLock = nil // so errorAndExit doesn't release another process's lock
+		}()
+	}
+
+	if !command.NoProfiles {
+		profiles, err = manager.List(context.Background(), profile.ListOptions{Scope: app.Scope})
+		if err != nil {
+			return err
+		}
+	}
+	return command.Func(app)
+}
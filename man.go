@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// manEscape escapes s for roff, and keeps a leading dot or quote from being
+// read as a request.
+func manEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// manOption formats a flag with its value, e.g. \-\-profile \fIstring\fR.
+func manOption(f *flag.Flag) string {
+	option := `\fB` + manEscape(completionFlag{Name: f.Name}.option()) + `\fR`
+	if name, _ := flag.UnquoteUsage(f); name != "" {
+		option += ` \fI` + name + `\fR`
+	}
+	return option
+}
+
+// manPage returns the manual page in roff format, built from the commands
+// and flags so it can't get out of date. Installed as git-sw.1, it's shown
+// by 'git help sw'.
+func manPage() string {
+	sb := new(strings.Builder)
+	sb.WriteString(`'\" t
+.TH GIT\-SW 1 "" "git\-sw" "Git Manual"
+.SH NAME
+git\-sw \- switch between multiple git profiles
+.SH SYNOPSIS
+\fBgit sw\fR \fIcommand\fR [\fIoptions\fR] [\fIarguments\fR]
+.SH DESCRIPTION
+A profile is a set of git config, such as user.name and user.email, that
+git\-sw includes in the config of a repository or in the global config.
+Without \fB\-\-no\-tui\fR, the profile and other missing values are asked for
+interactively.
+.PP
+Options go after the command they belong to, but can also be given before it.
+.SH COMMANDS
+`)
+	for _, actionName := range actionString[1:] {
+		command := commands[getAction(actionName)]
+		fmt.Fprintf(sb, ".TP\n\\fB%s\\fR", manEscape(actionName))
+		if command.Args != "" {
+			fmt.Fprintf(sb, " \\fI%s\\fR", manEscape(command.Args))
+		}
+		fmt.Fprintf(sb, "\n%s\n", manEscape(command.Description))
+		if len(command.Flags) > 0 {
+			options := make([]string, 0, len(command.Flags))
+			for _, name := range command.Flags {
+				options = append(options, `\fB`+manEscape(completionFlag{Name: name}.option())+`\fR`)
+			}
+			fmt.Fprintf(sb, ".br\nOptions: %s\n", strings.Join(options, ", "))
+		}
+	}
+	sb.WriteString(".SH OPTIONS\n")
+	flag.VisitAll(func(f *flag.Flag) {
+		_, usage := flag.UnquoteUsage(f)
+		fmt.Fprintf(sb, ".TP\n%s\n%s\n", manOption(f), manEscape(usage))
+	})
+	sb.WriteString(`.SH CONFIGURATION
+The settings of git\-sw are read from \fI~/.config/git\-sw/config\fR, which has
+the format of a git config file and is changed with \fBconfig set\fR. Each can
+be overridden with an environment variable, and some in the git config, which
+allows setting them per repository.
+`)
+	for _, s := range settings {
+		fmt.Fprintf(sb, ".TP\n\\fB%s\\fR (\\fB%s\\fR", manEscape(s.Key), manEscape(s.Env))
+		if s.GitKey != "" {
+			fmt.Fprintf(sb, ", \\fB%s\\fR in the git config", manEscape(s.GitKey))
+		}
+		fmt.Fprintf(sb, ")\n%s\n", manEscape(s.Description))
+	}
+	fmt.Fprintf(sb, `.PP
+The remotes and directories a profile is meant for are set in the profile
+itself, as \fB%s\fR and \fB%s\fR. They are used by \fBcheck\fR, \fBscan\fR
+and \fBdoctor\fR.
+.SH ENVIRONMENT
+.TP
+\fB%s\fR
+The profile applied by \fBexec\fR or \fBactivate\fR.
+.TP
+\fBGIT_DIR\fR
+The git directory of the repository, also set by \fB\-\-git\-dir\fR.
+.SH EXIT STATUS
+git\-sw exits with 0 on success, with the status of the command for \fBexec\fR,
+and otherwise with the status of the error, which \fB\-\-output json\fR
+prints together with its code. Errors without a code exit with 1.
+.TS
+l l l.
+%s
+.TE
+.SH SEE ALSO
+\fBgit\-config\fR(1), \fBgithooks\fR(5)
+`, manEscape(remoteBindingKey), manEscape(directoryBindingKey), manEscape(activeProfileEnv), manErrorCodes())
+	return sb.String()
+}
+
+// manErrorCodes returns the rows of the table of error codes.
+func manErrorCodes() string {
+	rows := make([]string, 0, len(errorCodes))
+	for _, c := range errorCodes {
+		rows = append(rows, fmt.Sprintf("%d\t%s\t%s", c.Status, manEscape(c.Code), manEscape(c.Err.Error())))
+	}
+	return strings.Join(rows, "\n")
+}
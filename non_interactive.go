@@ -1,43 +1,66 @@
 package main
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
+	"io"
 	"net/mail"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/thansetan/git-sw/pkg/gitconfig"
+	"github.com/thansetan/git-sw/pkg/profile"
 	"golang.org/x/crypto/ssh"
 )
 
 // NoTUI implements UserInterface for non-interactive (automated/scripted) usage.
-type NoTUI struct{}
+type NoTUI struct {
+	Out io.Writer // where lists are written
+}
 
 var (
 	ErrMissingProfile    = errors.New("missing required flag: --profile")
 	ErrMissingName       = errors.New("missing required flag: --name")
 	ErrMissingEmail      = errors.New("missing required flag: --email")
-	ErrProfileNotFound   = errors.New("profile not found")
+	ErrProfileNotFound   = profile.ErrProfileNotFound
 	ErrEditNoTUI         = errors.New("interactive edit is not supported in --no-tui mode")
 	ErrDeleteNoConfirm   = errors.New("delete in --no-tui mode requires --yes flag for safety")
 	ErrInvalidKeyFormat  = errors.New("invalid key format: must be 'openpgp', 'ssh', or 'x509'")
 	ErrMissingSigningKey = errors.New("--signing-key is required when --key-format is specified")
+	ErrMissingBackup     = errors.New("missing required flag: --backup")
+	ErrMissingCredURL    = errors.New("--credential-url is required when --credential-username is specified")
+	ErrMissingNewName    = errors.New("missing required flag: --new-name")
+	ErrInvalidSetting    = errors.New("invalid setting: must be key=value")
 )
 
-func (n *NoTUI) CreateProfile() (Profile, error) {
+// profileRecord is what a profile is created from without prompts: the flags
+// of create, or a record read by create --from.
+type profileRecord struct {
+	Profile    string   `json:"profile"`
+	Name       string   `json:"name"`
+	Email      string   `json:"email"`
+	SigningKey string   `json:"signing_key,omitempty"`
+	KeyFormat  string   `json:"key_format,omitempty"`
+	Settings   []string `json:"settings,omitempty"` // extra key=value settings
+}
+
+// newProfile validates r and returns the profile it describes, which mustn't
+// have the name of one of profiles.
+func (r profileRecord) newProfile(profiles []Profile) (Profile, error) {
 	var profile Profile
 	profile.Config = gitconfig.New()
 
-	// Validate required flags
-	if profileFlag == "" {
+	// Validate required fields
+	if r.Profile == "" {
 		return Profile{}, ErrMissingProfile
 	}
-	if nameFlag == "" {
+	if r.Name == "" {
 		return Profile{}, ErrMissingName
 	}
-	if emailFlag == "" {
+	if r.Email == "" {
 		return Profile{}, ErrMissingEmail
 	}
 
@@ -46,48 +69,45 @@ func (n *NoTUI) CreateProfile() (Profile, error) {
 	for i := range profiles {
 		profileMap[strings.ToLower(profiles[i].Name)] = struct{}{}
 	}
-	if _, ok := profileMap[strings.ToLower(profileFlag)]; ok {
+	if _, ok := profileMap[strings.ToLower(r.Profile)]; ok {
 		return Profile{}, ErrDuplicateProfile
 	}
 
 	// Validate email format
-	_, err := mail.ParseAddress(emailFlag)
+	_, err := mail.ParseAddress(r.Email)
 	if err != nil {
 		return Profile{}, ErrInvalidEmail
 	}
 
 	// Validate gitconfig values
-	if err := gitconfig.ValidateValue(nameFlag); err != nil {
+	if err := gitconfig.ValidateValue(r.Name); err != nil {
 		return Profile{}, fmt.Errorf("invalid name: %w", err)
 	}
-	if err := gitconfig.ValidateValue(emailFlag); err != nil {
+	if err := gitconfig.ValidateValue(r.Email); err != nil {
 		return Profile{}, fmt.Errorf("invalid email: %w", err)
 	}
 
 	// Set profile name
-	profile.Name = profileFlag
+	profile.Name = r.Profile
 
 	// Set user.name
-	if err := profile.Config.Set("user.name", nameFlag); err != nil {
+	if err := profile.Config.Set("user.name", r.Name); err != nil {
 		return Profile{}, err
 	}
 
 	// Set user.email
-	if err := profile.Config.Set("user.email", emailFlag); err != nil {
+	if err := profile.Config.Set("user.email", r.Email); err != nil {
 		return Profile{}, err
 	}
 
 	// Handle signing key configuration
-	if keyFormatFlag != "" || signingKeyFlag != "" {
-		if signingKeyFlag == "" {
+	if r.KeyFormat != "" || r.SigningKey != "" {
+		if r.SigningKey == "" {
 			return Profile{}, ErrMissingSigningKey
 		}
 
-		// Determine key format: use provided format or default to openpgp
-		keyFormat := GPGFormat(strings.ToLower(keyFormatFlag))
-		if keyFormat == "" {
-			keyFormat = OPENPGP
-		}
+		// Determine key format: use provided format or the create.keyFormat setting
+		keyFormat := GPGFormat(strings.ToLower(cmp.Or(r.KeyFormat, getSetting(keyFormatSettingKey))))
 
 		// Validate key format
 		validFormat := false
@@ -102,16 +122,16 @@ func (n *NoTUI) CreateProfile() (Profile, error) {
 		}
 
 		// Validate signing key based on format
-		if err := gitconfig.ValidateValue(signingKeyFlag); err != nil {
+		if err := gitconfig.ValidateValue(r.SigningKey); err != nil {
 			return Profile{}, fmt.Errorf("invalid signing key: %w", err)
 		}
 
 		if keyFormat == SSH {
 			// Validate SSH public key
-			if filepath.Ext(signingKeyFlag) != ".pub" {
+			if filepath.Ext(r.SigningKey) != ".pub" {
 				return Profile{}, ErrInvalidPublicKeyExt
 			}
-			content, err := os.ReadFile(signingKeyFlag)
+			content, err := os.ReadFile(r.SigningKey)
 			if err != nil {
 				return Profile{}, fmt.Errorf("cannot read SSH key: %w", err)
 			}
@@ -127,7 +147,7 @@ func (n *NoTUI) CreateProfile() (Profile, error) {
 		}
 
 		// Set signing key
-		if err := profile.Config.Set("user.signingKey", signingKeyFlag); err != nil {
+		if err := profile.Config.Set("user.signingKey", r.SigningKey); err != nil {
 			return Profile{}, err
 		}
 
@@ -138,33 +158,108 @@ func (n *NoTUI) CreateProfile() (Profile, error) {
 
 		// Set GPG program for openpgp format
 		if keyFormat == OPENPGP {
-			gpgProg := gpgProgramFlag
-			if gpgProg == "" {
-				gpgProg = "gpg" // default
-			}
+			gpgProg := cmp.Or(gpgProgramFlag, getSetting(gpgProgramSettingKey))
 			if err := profile.Config.Set("gpg.program", gpgProg); err != nil {
 				return Profile{}, err
 			}
 		}
 	}
 
+	// Handle the extra settings
+	for _, setting := range r.Settings {
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return Profile{}, fmt.Errorf("%w: %q", ErrInvalidSetting, setting)
+		}
+		if err := profile.Config.Set(key, value); err != nil {
+			return Profile{}, fmt.Errorf("invalid setting %q: %w", setting, err)
+		}
+	}
+
 	return profile, nil
 }
 
-func (n *NoTUI) SelectProfile(profiles []Profile) (Profile, error) {
-	profileName := profileFlag
-	if profileName == "" {
-		return Profile{}, ErrMissingProfile
+func (n *NoTUI) CreateProfile() (Profile, error) {
+	record := profileRecord{
+		Profile:    profileFlag,
+		Name:       nameFlag,
+		Email:      emailFlag,
+		SigningKey: signingKeyFlag,
+		KeyFormat:  keyFormatFlag,
+	}
+	profile, err := record.newProfile(profiles)
+	if err != nil {
+		return Profile{}, err
 	}
 
-	// Find the profile
-	for _, p := range profiles {
-		if strings.EqualFold(p.Name, profileName) {
-			return p, nil
+	// Handle SSH identity configuration
+	if sshKeyFlag != "" {
+		if err := validateSSHIdentity(sshKeyFlag); err != nil {
+			return Profile{}, fmt.Errorf("invalid SSH key: %w", err)
+		}
+		identityPath, err := sshIdentityPath(sshKeyFlag)
+		if err != nil {
+			return Profile{}, err
+		}
+		if err := profile.Config.Set("core.sshCommand", sshCommand(identityPath)); err != nil {
+			return Profile{}, err
 		}
 	}
 
-	return Profile{}, fmt.Errorf("%w: %s", ErrProfileNotFound, profileName)
+	// Handle remote and directory bindings
+	if err := addBindings(profile.Config, remoteFlag, directoryFlag); err != nil {
+		return Profile{}, err
+	}
+
+	// Handle HTTPS credential configuration
+	if err := applyCredentialFlags(profile.Config); err != nil {
+		return Profile{}, err
+	}
+
+	return profile, nil
+}
+
+// hasCredentialFlags reports whether any of the credential flags was given.
+func hasCredentialFlags() bool {
+	return credentialURLFlag != "" || credentialUsernameFlag != "" || credentialHelperFlag != "" || isFlagSet("credential-use-http-path")
+}
+
+// applyCredentialFlags writes the credential flags that were given to config.
+func applyCredentialFlags(config *gitconfig.GitConfig) error {
+	if credentialUsernameFlag != "" {
+		if credentialURLFlag == "" {
+			return ErrMissingCredURL
+		}
+		err := validateCredentialURL(credentialURLFlag)
+		if err != nil {
+			return err
+		}
+		if err := gitconfig.ValidateValue(credentialUsernameFlag); err != nil {
+			return fmt.Errorf("invalid credential username: %w", err)
+		}
+		err = config.Set(fmt.Sprintf("credential.%s.username", credentialURLFlag), credentialUsernameFlag)
+		if err != nil {
+			return err
+		}
+	}
+	if credentialHelperFlag != "" {
+		if err := config.Set("credential.helper", credentialHelperFlag); err != nil {
+			return fmt.Errorf("invalid credential helper: %w", err)
+		}
+	}
+	if isFlagSet("credential-use-http-path") {
+		if err := config.Set("credential.useHttpPath", credentialUseHTTPPathFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *NoTUI) SelectProfile(profiles []Profile) (Profile, error) {
+	if profileFlag == "" {
+		return Profile{}, ErrMissingProfile
+	}
+	return findProfile(profiles, profileFlag)
 }
 
 func (n *NoTUI) ListProfiles(profiles []Profile) error {
@@ -173,14 +268,14 @@ func (n *NoTUI) ListProfiles(profiles []Profile) error {
 		if p.IsActive {
 			status = " (active)"
 		}
-		fmt.Printf("%s%s\n", p.Name, status)
+		fmt.Fprintf(n.Out, "%s%s\n", p.Name, status)
 	}
 	return nil
 }
 
 func (n *NoTUI) ConfirmDelete() bool {
 	// In non-interactive mode, require --yes flag for safety
-	if !yesFlag {
+	if !yesFlag && getBoolSetting(confirmSettingKey) {
 		fmt.Fprintln(os.Stderr, ErrDeleteNoConfirm)
 		return false
 	}
@@ -188,5 +283,102 @@ func (n *NoTUI) ConfirmDelete() bool {
 }
 
 func (n *NoTUI) EditProfile(path string) error {
-	return ErrEditNoTUI
+	// Only the credential settings of a profile can be edited without an editor
+	if !hasCredentialFlags() || filepath.Dir(filepath.Dir(path)) != saveDirPath {
+		return ErrEditNoTUI
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	config, err := gitconfig.Parse(content)
+	if err != nil {
+		return err
+	}
+	err = applyCredentialFlags(config)
+	if err != nil {
+		return err
+	}
+	return config.Save(path)
+}
+
+func (n *NoTUI) SelectBackup(backups []Backup) (Backup, error) {
+	if backupFlag == "" {
+		// list the backups so the caller can pick an ID
+		for _, b := range backups {
+			fmt.Fprintf(n.Out, "%s\t%s\t%s\n", b.ID, b.Kind, b.Name)
+		}
+		return Backup{}, ErrMissingBackup
+	}
+
+	for _, b := range backups {
+		if b.ID == backupFlag {
+			return b, nil
+		}
+	}
+
+	return Backup{}, fmt.Errorf("%w: %s", ErrBackupNotFound, backupFlag)
+}
+
+func (n *NoTUI) RenameProfile(profile Profile, profiles []Profile) (string, error) {
+	if newNameFlag == "" {
+		return "", ErrMissingNewName
+	}
+	err := validateNewProfileName(newNameFlag, profile, profiles)
+	if err != nil {
+		return "", err
+	}
+	return newNameFlag, nil
+}
+
+func (n *NoTUI) ListHistory(entries []JournalEntry) error {
+	undone := undoneEntries(entries)
+	for _, e := range entries {
+		status := ""
+		if undone[e.ID] {
+			status = " (undone)"
+		}
+		fmt.Fprintf(n.Out, "%d\t%s\t%s\t%s%s\t%s\n", e.ID, e.Time.Format(time.RFC3339), e.Command, e.Profile, status, strings.Join(e.Repos, ","))
+	}
+	return nil
+}
+
+func (n *NoTUI) ListRepos(profile Profile, uses []RepoUse) error {
+	for _, use := range uses {
+		fmt.Fprintf(n.Out, "%s\t%s\t%s\n", use.Scope, use.Config, use.Repo)
+	}
+	return nil
+}
+
+func (n *NoTUI) Confirm(label string) bool {
+	// In non-interactive mode, --yes confirms everything
+	return yesFlag || !getBoolSetting(confirmSettingKey)
+}
+
+func (n *NoTUI) PromptEmail(profile Profile) (string, error) {
+	if emailFlag == "" {
+		return "", ErrMissingEmail
+	}
+	_, err := mail.ParseAddress(emailFlag)
+	if err != nil {
+		return "", ErrInvalidEmail
+	}
+	if err := gitconfig.ValidateValue(emailFlag); err != nil {
+		return "", fmt.Errorf("invalid email: %w", err)
+	}
+	return emailFlag, nil
+}
+
+func (n *NoTUI) ListScan(results []ScanResult) error {
+	for _, r := range results {
+		status := "ok"
+		switch {
+		case r.Err != nil:
+			status = "error: " + r.Err.Error()
+		case r.Mismatch != "":
+			status = "mismatch: " + r.Mismatch
+		}
+		fmt.Fprintf(n.Out, "%s\t%s\t%s\t%s\t%s\n", r.Path, r.Profile, r.Email, strings.Join(r.Remotes, ","), status)
+	}
+	return nil
 }
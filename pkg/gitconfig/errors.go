@@ -15,6 +15,11 @@ var (
 	ErrInvalidVariableName  = errors.New("illegal characters in variable name")
 	ErrInvalidVariableValue = errors.New("illegal characters in variable value")
 	ErrInvalidLine          = errors.New("illegal characters in line")
+	ErrLocked               = errors.New("config file is locked by another process")
+	ErrNotLocked            = errors.New("config file isn't locked for writing")
+	ErrNoRepository         = errors.New("not a git repository")
+	ErrInvalidBool          = errors.New("invalid boolean value")
+	ErrUnencodableValue     = errors.New("value can't be written to a config file")
 )
 
 // ParseError returned if there's an error while parsing
@@ -0,0 +1,452 @@
+package gitconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+const lockSuffix = ".lock"
+
+// fileLine is a logical line of a config file. A variable whose value
+// continues on the next lines is a single fileLine.
+type fileLine struct {
+	text    string // raw text, including the line break(s)
+	typ     lineType
+	section Section // section the line belongs to
+	name    VariableName
+	value   Value
+}
+
+func (l fileLine) matches(section Section, name VariableName) bool {
+	return l.typ == variable && strings.EqualFold(l.section.Name, section.Name) &&
+		l.section.Subsection == section.Subsection && strings.EqualFold(string(l.name), string(name))
+}
+
+// File is a config file on disk that is edited in place. Unlike GitConfig,
+// only the lines of the variables that are changed are touched, so comments
+// and formatting of the rest of the file are kept as is.
+//
+// A File returned by LockFile holds a git-compatible lock (path + ".lock")
+// until either Commit or Unlock is called.
+type File struct {
+	path  string
+	lines []fileLine
+	lock  *os.File
+}
+
+// OpenFile reads the config file at path for reading. A file that doesn't
+// exist is treated as an empty one.
+func OpenFile(path string) (*File, error) {
+	f := &File{path: path}
+	err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// LockFile locks the config file at path the way git does, by creating
+// path + ".lock", and reads it for editing. It fails with ErrLocked if
+// the file is already locked by another process. If path is a symlink, the
+// file it points to is locked and replaced, like git does.
+func LockFile(path string) (*File, error) {
+	path, err := ResolveLink(path)
+	if err != nil {
+		return nil, err
+	}
+	err = ensureDir(path)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(path+lockSuffix, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("%w: %s", ErrLocked, path+lockSuffix)
+		}
+		return nil, err
+	}
+	f := &File{path: path, lock: lock}
+	err = f.read()
+	if err != nil {
+		f.Unlock()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the path of the config file, which is the file a symlink
+// points to for a File returned by LockFile.
+func (f *File) Path() string {
+	return f.path
+}
+
+// ParseFile parses content as the config file at path, such as a copy of it
+// saved earlier, without reading the file. It can't be committed.
+func ParseFile(path string, content []byte) (*File, error) {
+	f := &File{path: path}
+	err := f.parse(content)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) read() error {
+	content, err := os.ReadFile(f.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return f.parse(content)
+}
+
+func (f *File) parse(content []byte) (err error) {
+	var (
+		sec        Section
+		lineNumber = 1
+		physical   = strings.SplitAfter(string(content), "\n")
+	)
+	for i := 0; i < len(physical); i++ {
+		text := physical[i]
+		if text == "" {
+			continue
+		}
+		c := new(configFile)
+		c.init([]byte(text))
+		c.trimSpaceLeft()
+		l := fileLine{typ: c.getType(), section: sec}
+		switch l.typ {
+		case section:
+			sec, err = c.parseSection()
+			if err != nil {
+				return &ParseError{Err: err, Line: strings.TrimRight(text, "\r\n"), LineNumber: lineNumber}
+			}
+			l.section = sec
+		case variable:
+			for continues(text) && i+1 < len(physical) {
+				i++
+				text += physical[i]
+			}
+			c.init([]byte(text))
+			c.trimSpaceLeft()
+			var implicit bool
+			l.name, implicit, err = c.parseVariable()
+			if err != nil {
+				return &ParseError{Err: err, Line: strings.TrimRight(text, "\r\n"), LineNumber: lineNumber}
+			}
+			l.value = Value{v: string(c.buff), implicit: implicit}
+		case comment, end:
+		default:
+			if strings.TrimSpace(text) != "" {
+				return &ParseError{Err: ErrInvalidLine, Line: strings.TrimRight(text, "\r\n"), LineNumber: lineNumber}
+			}
+		}
+		l.text = text
+		lineNumber += strings.Count(text, "\n")
+		f.lines = append(f.lines, l)
+	}
+
+	return nil
+}
+
+// continues reports whether a physical line ends with a '\' that escapes
+// the line break, i.e. the value continues on the next line.
+func continues(line string) bool {
+	line = strings.TrimRight(line, "\r\n")
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// GetAll retrieves all values of a given key, in the order they appear in the file.
+func (f *File) GetAll(key string) ([]Value, error) {
+	section, name, err := GitConfig{}.splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+	var values []Value
+	for _, l := range f.lines {
+		if l.matches(section, name) {
+			values = append(values, l.value)
+		}
+	}
+	if len(values) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return values, nil
+}
+
+// UnsetAll removes every value of key that matches valuePattern, or every value
+// of key if valuePattern is nil. It returns the number of values removed.
+// Sections left empty are removed as well.
+func (f *File) UnsetAll(key string, valuePattern *regexp.Regexp) (int, error) {
+	section, name, err := GitConfig{}.splitKey(key)
+	if err != nil {
+		return 0, err
+	}
+	_, n := f.unsetAll(section, name, valuePattern)
+	if n > 0 {
+		f.removeEmptySections(section)
+	}
+	return n, nil
+}
+
+// unsetAll removes the matching lines and returns the index the last one had
+// (or -1 if nothing was removed) along with the number of lines removed.
+func (f *File) unsetAll(sec Section, name VariableName, valuePattern *regexp.Regexp) (int, int) {
+	var (
+		lines = f.lines[:0]
+		last  = -1
+		n     int
+	)
+	for _, l := range f.lines {
+		if l.matches(sec, name) && (valuePattern == nil || valuePattern.MatchString(l.value.String())) {
+			last = len(lines)
+			n++
+			continue
+		}
+		lines = append(lines, l)
+	}
+	f.lines = lines
+	return last, n
+}
+
+// removeEmptySections removes the headers of sec that have neither variables
+// nor comments left, like git does after unsetting a variable.
+func (f *File) removeEmptySections(sec Section) {
+	lines := f.lines[:0]
+	for i, l := range f.lines {
+		if l.typ == section && l.section == sec && f.isEmptySection(i) {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	f.lines = lines
+}
+
+func (f *File) isEmptySection(header int) bool {
+	for _, l := range f.lines[header+1:] {
+		switch l.typ {
+		case section:
+			return true
+		case variable, comment:
+			return false
+		}
+	}
+	return true
+}
+
+// ReplaceAll replaces every value of key that matches valuePattern (or every
+// value of key if valuePattern is nil) with a single value. The new value takes
+// the place of the last replaced one, or is added to the end of the last
+// section with the same name if there was nothing to replace. Like with git
+// config, value is written quoted and escaped as needed.
+func (f *File) ReplaceAll(key string, value interface{}, valuePattern *regexp.Regexp) error {
+	l, err := newFileLine(key, value)
+	if err != nil {
+		return err
+	}
+	at, _ := f.unsetAll(l.section, l.name, valuePattern)
+	f.insert(at, l)
+	return nil
+}
+
+// Add adds value to key, after the values it already has, like
+// git config --add.
+func (f *File) Add(key string, value interface{}) error {
+	l, err := newFileLine(key, value)
+	if err != nil {
+		return err
+	}
+	f.insert(-1, l)
+	return nil
+}
+
+// newFileLine returns the line of a variable.
+func newFileLine(key string, value interface{}) (fileLine, error) {
+	val, err := newValue(value)
+	if err != nil {
+		return fileLine{}, err
+	}
+	sec, name, err := GitConfig{}.splitKey(key)
+	if err != nil {
+		return fileLine{}, err
+	}
+	return fileLine{
+		text:    fmt.Sprintf("\t%s = %v\n", name, val.Value()),
+		typ:     variable,
+		section: sec,
+		name:    name,
+		value:   val,
+	}, nil
+}
+
+// insert inserts the variable line l at index at, or at the end of the last
+// section it belongs to if at is -1. The section is added if it doesn't exist.
+func (f *File) insert(at int, l fileLine) {
+	if at == -1 {
+		at = f.sectionEnd(l.section)
+	}
+	if at == -1 {
+		if len(f.lines) > 0 && !strings.HasSuffix(f.lines[len(f.lines)-1].text, "\n") {
+			f.lines[len(f.lines)-1].text += "\n"
+		}
+		f.lines = append(f.lines, fileLine{text: l.section.String() + "\n", typ: section, section: l.section})
+		at = len(f.lines)
+	}
+	f.lines = append(f.lines[:at], append([]fileLine{l}, f.lines[at:]...)...)
+}
+
+// sectionEnd returns the index right after the last variable of the last
+// occurrence of section, or -1 if the section doesn't exist.
+func (f *File) sectionEnd(sec Section) int {
+	at := -1
+	for i, l := range f.lines {
+		if !strings.EqualFold(l.section.Name, sec.Name) || l.section.Subsection != sec.Subsection {
+			continue
+		}
+		if l.typ == section || l.typ == variable {
+			at = i + 1
+		}
+	}
+	if at > 0 && !strings.HasSuffix(f.lines[at-1].text, "\n") {
+		f.lines[at-1].text += "\n"
+	}
+	return at
+}
+
+// Bytes returns the content of the file, including the changes made so far.
+func (f *File) Bytes() []byte {
+	sb := new(strings.Builder)
+	for _, l := range f.lines {
+		sb.WriteString(l.text)
+	}
+	return []byte(sb.String())
+}
+
+// Commit writes the changes to the lock file and renames it over the config
+// file, which also releases the lock.
+func (f *File) Commit() (err error) {
+	if f.lock == nil {
+		return ErrNotLocked
+	}
+	defer func() {
+		if err != nil {
+			f.Unlock()
+		}
+	}()
+
+	_, err = f.lock.Write(f.Bytes())
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(f.path); err == nil {
+		err = f.lock.Chmod(info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+	}
+	err = f.lock.Sync()
+	if err != nil {
+		return err
+	}
+	err = f.lock.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(f.lock.Name(), f.path)
+	if err != nil {
+		return err
+	}
+	f.lock = nil
+
+	return nil
+}
+
+// Unlock releases the lock without writing any changes. It's a no-op if the
+// file isn't locked (anymore), so it's safe to defer right after LockFile.
+func (f *File) Unlock() {
+	if f.lock == nil {
+		return
+	}
+	f.lock.Close()
+	os.Remove(f.lock.Name())
+	f.lock = nil
+}
+
+// ensureDir creates the parent directory of path if it doesn't exist yet.
+func ensureDir(path string) error {
+	return os.MkdirAll(filepath.Dir(path), 0o755)
+}
+
+// ResolveLink follows path while it's a symlink and returns the path of the
+// file it ends at, which may not exist yet.
+func ResolveLink(path string) (string, error) {
+	for range 255 {
+		info, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return path, nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+	return "", &fs.PathError{Op: "readlink", Path: path, Err: syscall.ELOOP}
+}
+
+// WriteFileAtomic writes data to a temporary file that is renamed to path,
+// so path either keeps its old content or gets all of the new one. If path is
+// a symlink, the file it points to is replaced instead of the link.
+func WriteFileAtomic(path string, data []byte, perm fs.FileMode) (err error) {
+	path, err = ResolveLink(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	_, err = f.Write(data)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(f.Name(), perm)
+	if err != nil {
+		return err
+	}
+	// Windows can't replace a read-only file
+	err = os.Chmod(path, 0o644)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
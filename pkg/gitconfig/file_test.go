@@ -0,0 +1,383 @@
+package gitconfig
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const fileContent = `# a comment that must be kept
+[user]
+	name = foo ; another comment
+	email = foo@example.com
+[include]
+	path = /home/foo/.config/git-sw/a/.gitconfig
+	path = ~/other.gitconfig
+[core]
+	editor = vim
+`
+
+func writeTempConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(path, []byte(content), 0o644)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error = %v, want %v", err, nil)
+	}
+	return path
+}
+
+func gitGetAll(t *testing.T, path, key string) string {
+	t.Helper()
+	gitOutput, err := exec.Command("git", "config", "--file", path, "--get-all", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(gitOutput))
+}
+
+func TestFile_ReplaceAll(t *testing.T) {
+	pattern := regexp.MustCompile(`git-sw.*gitconfig$`)
+	tests := []struct {
+		name    string
+		content string
+		key     string
+		value   string
+		want    string
+	}{
+		{
+			name:    "Replace Matching Value",
+			content: fileContent,
+			key:     "include.path",
+			value:   "/home/foo/.config/git-sw/b/.gitconfig",
+			want: `# a comment that must be kept
+[user]
+	name = foo ; another comment
+	email = foo@example.com
+[include]
+	path = /home/foo/.config/git-sw/b/.gitconfig
+	path = ~/other.gitconfig
+[core]
+	editor = vim
+`,
+		},
+		{
+			name:    "Add To Existing Section",
+			content: "[include]\n\tpath = ~/other.gitconfig\n[core]\n\teditor = vim",
+			key:     "include.path",
+			value:   "/home/foo/.config/git-sw/b/.gitconfig",
+			want:    "[include]\n\tpath = ~/other.gitconfig\n\tpath = /home/foo/.config/git-sw/b/.gitconfig\n[core]\n\teditor = vim",
+		},
+		{
+			name:    "Add New Section",
+			content: "[core]\n\teditor = vim",
+			key:     "include.path",
+			value:   "/home/foo/.config/git-sw/b/.gitconfig",
+			want:    "[core]\n\teditor = vim\n[include]\n\tpath = /home/foo/.config/git-sw/b/.gitconfig\n",
+		},
+		{
+			name:  "Empty File",
+			key:   "include.path",
+			value: "/home/foo/.config/git-sw/b/.gitconfig",
+			want:  "[include]\n\tpath = /home/foo/.config/git-sw/b/.gitconfig\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTempConfig(t, tt.content)
+			f, err := LockFile(path)
+			if err != nil {
+				t.Fatalf("LockFile() error = %v, want %v", err, nil)
+			}
+			defer f.Unlock()
+			err = f.ReplaceAll(tt.key, tt.value, pattern)
+			if err != nil {
+				t.Fatalf("File.ReplaceAll() error = %v, want %v", err, nil)
+			}
+			err = f.Commit()
+			if err != nil {
+				t.Fatalf("File.Commit() error = %v, want %v", err, nil)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("os.ReadFile() error = %v, want %v", err, nil)
+			}
+			if string(got) != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+			if _, err := os.Stat(path + lockSuffix); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("lock file still exists after Commit()")
+			}
+			if gitValues := gitGetAll(t, path, "include.path"); !strings.Contains(gitValues, tt.value) {
+				t.Errorf("git config --get-all = %q, want it to contain %q", gitValues, tt.value)
+			}
+		})
+	}
+}
+
+func TestFile_Add(t *testing.T) {
+	path := writeTempConfig(t, "# a comment that must be kept\n[url \"git@github.com-work:\"]\n\tinsteadOf = git@github.com:\n[core]\n\teditor = vim\n")
+	f, err := LockFile(path)
+	if err != nil {
+		t.Fatalf("LockFile() error = %v, want %v", err, nil)
+	}
+	defer f.Unlock()
+	err = f.Add("url.git@github.com-work:.insteadOf", "ssh://git@github.com/")
+	if err != nil {
+		t.Fatalf("File.Add() error = %v, want %v", err, nil)
+	}
+	err = f.Commit()
+	if err != nil {
+		t.Fatalf("File.Commit() error = %v, want %v", err, nil)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v, want %v", err, nil)
+	}
+	want := "# a comment that must be kept\n[url \"git@github.com-work:\"]\n\tinsteadOf = git@github.com:\n\tinsteadOf = ssh://git@github.com/\n[core]\n\teditor = vim\n"
+	if string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+	if gitValues := gitGetAll(t, path, "url.git@github.com-work:.insteadOf"); gitValues != "git@github.com:\nssh://git@github.com/" {
+		t.Errorf("git config --get-all = %q, want both values", gitValues)
+	}
+}
+
+func TestFile_ReplaceAllEncodesValue(t *testing.T) {
+	values := []string{
+		`C:\Users\me\.config\git-sw\a\.gitconfig`,
+		"/home/me/#git-sw;/a/.gitconfig",
+		"!f() { echo \"username=me\"; }; f",
+		" leading and trailing spaces ",
+		"a\ttab and a\nnewline",
+	}
+	for _, value := range values {
+		path := writeTempConfig(t, fileContent)
+		f, err := LockFile(path)
+		if err != nil {
+			t.Fatalf("LockFile() error = %v, want %v", err, nil)
+		}
+		err = f.ReplaceAll("foo.bar", value, nil)
+		if err != nil {
+			f.Unlock()
+			t.Fatalf("File.ReplaceAll(%q) error = %v, want %v", value, err, nil)
+		}
+		got, err := f.GetAll("foo.bar")
+		if err != nil || join(got) != value {
+			t.Errorf("File.GetAll() = (%q, %v), want (%q, %v)", join(got), err, value, nil)
+		}
+		err = f.Commit()
+		if err != nil {
+			t.Fatalf("File.Commit() error = %v, want %v", err, nil)
+		}
+		gitOutput, err := exec.Command("git", "config", "--file", path, "foo.bar").Output()
+		if err != nil || strings.TrimSuffix(string(gitOutput), "\n") != value {
+			t.Errorf("git config foo.bar = (%q, %v), want (%q, %v)", gitOutput, err, value, nil)
+		}
+	}
+
+	f, err := LockFile(writeTempConfig(t, fileContent))
+	if err != nil {
+		t.Fatalf("LockFile() error = %v, want %v", err, nil)
+	}
+	defer f.Unlock()
+	err = f.ReplaceAll("foo.bar", "a\x00null byte", nil)
+	if !errors.Is(err, ErrUnencodableValue) {
+		t.Errorf("File.ReplaceAll() error = %v, want %v", err, ErrUnencodableValue)
+	}
+}
+
+func TestFile_UnsetAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		pattern *regexp.Regexp
+		wantN   int
+		want    string
+	}{
+		{
+			name:    "Matching Values Only",
+			key:     "include.path",
+			pattern: regexp.MustCompile(`git-sw.*gitconfig$`),
+			wantN:   1,
+			want:    "~/other.gitconfig",
+		},
+		{
+			name:  "All Values",
+			key:   "include.path",
+			wantN: 2,
+		},
+		{
+			name:  "Case Insensitive Key",
+			key:   "USER.Email",
+			wantN: 1,
+		},
+		{
+			name:    "No Match",
+			key:     "include.path",
+			pattern: regexp.MustCompile(`nothing`),
+			wantN:   0,
+			want:    "/home/foo/.config/git-sw/a/.gitconfig\n~/other.gitconfig",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTempConfig(t, fileContent)
+			f, err := LockFile(path)
+			if err != nil {
+				t.Fatalf("LockFile() error = %v, want %v", err, nil)
+			}
+			defer f.Unlock()
+			n, err := f.UnsetAll(tt.key, tt.pattern)
+			if err != nil || n != tt.wantN {
+				t.Fatalf("File.UnsetAll() = (%v, %v), want (%v, %v)", n, err, tt.wantN, nil)
+			}
+			err = f.Commit()
+			if err != nil {
+				t.Fatalf("File.Commit() error = %v, want %v", err, nil)
+			}
+			if got := gitGetAll(t, path, strings.ToLower(tt.key)); got != tt.want {
+				t.Errorf("git config --get-all %s = %q, want %q", tt.key, got, tt.want)
+			}
+			if got := gitGetAll(t, path, "core.editor"); got != "vim" {
+				t.Errorf("git config --get-all core.editor = %q, want %q", got, "vim")
+			}
+		})
+	}
+}
+
+func TestFile_GetAll(t *testing.T) {
+	path := writeTempConfig(t, fileContent+"[foo]\n\tbar = \"a value\" \\\n\"that continues\"\n")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v, want %v", err, nil)
+	}
+	for _, key := range []string{"user.name", "user.email", "include.path", "foo.bar"} {
+		values, err := f.GetAll(key)
+		if err != nil {
+			t.Errorf("File.GetAll(%s) error = %v, want %v", key, err, nil)
+		}
+		if got, want := join(values), gitGetAll(t, path, key); got != want {
+			t.Errorf("File.GetAll(%s) = %q, want %q", key, got, want)
+		}
+	}
+	_, err = f.GetAll("foo.baz")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("File.GetAll(foo.baz) error = %v, want %v", err, ErrKeyNotFound)
+	}
+	if err := f.Commit(); !errors.Is(err, ErrNotLocked) {
+		t.Errorf("File.Commit() error = %v, want %v", err, ErrNotLocked)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing")
+	f, err := ParseFile(path, []byte(fileContent))
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v, want %v", err, nil)
+	}
+	if got := string(f.Bytes()); got != fileContent {
+		t.Errorf("File.Bytes() = %q, want %q", got, fileContent)
+	}
+	if err := f.Commit(); !errors.Is(err, ErrNotLocked) {
+		t.Errorf("File.Commit() error = %v, want %v", err, ErrNotLocked)
+	}
+	var parseErr *ParseError
+	if _, err := ParseFile(path, []byte("[user\n")); !errors.As(err, &parseErr) {
+		t.Errorf("ParseFile() error = %v, want a *ParseError", err)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := writeTempConfig(t, fileContent)
+	f, err := LockFile(path)
+	if err != nil {
+		t.Fatalf("LockFile() error = %v, want %v", err, nil)
+	}
+	_, err = LockFile(path)
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("second LockFile() error = %v, want %v", err, ErrLocked)
+	}
+	f.Unlock()
+	f, err = LockFile(path)
+	if err != nil {
+		t.Fatalf("LockFile() after Unlock() error = %v, want %v", err, nil)
+	}
+	f.Unlock()
+}
+
+func TestLockFile_Symlink(t *testing.T) {
+	target := writeTempConfig(t, fileContent)
+	link := filepath.Join(t.TempDir(), ".gitconfig")
+	err := os.Symlink(target, link)
+	if err != nil {
+		t.Skipf("os.Symlink() error = %v", err)
+	}
+	f, err := LockFile(link)
+	if err != nil {
+		t.Fatalf("LockFile() error = %v, want %v", err, nil)
+	}
+	if _, err := os.Stat(target + lockSuffix); err != nil {
+		t.Errorf("lock of the target: os.Stat() error = %v, want %v", err, nil)
+	}
+	err = f.ReplaceAll("user.name", "linked", nil)
+	if err != nil {
+		t.Fatalf("File.ReplaceAll() error = %v, want %v", err, nil)
+	}
+	err = f.Commit()
+	if err != nil {
+		t.Fatalf("File.Commit() error = %v, want %v", err, nil)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("File.Commit() replaced the symlink (error = %v)", err)
+	}
+	if got := gitGetAll(t, target, "user.name"); got != "linked" {
+		t.Errorf("user.name of the target = %q, want %q", got, "linked")
+	}
+}
+
+func TestFile_UnsetAllRemovesEmptySection(t *testing.T) {
+	path := writeTempConfig(t, "[include]\n\tpath = /a/git-sw/b/.gitconfig\n[alias]\n[core]\n\teditor = vim\n")
+	f, err := LockFile(path)
+	if err != nil {
+		t.Fatalf("LockFile() error = %v, want %v", err, nil)
+	}
+	defer f.Unlock()
+	_, err = f.UnsetAll("include.path", nil)
+	if err != nil {
+		t.Fatalf("File.UnsetAll() error = %v, want %v", err, nil)
+	}
+	want := "[alias]\n[core]\n\teditor = vim\n"
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	target, link := filepath.Join(dir, "target"), filepath.Join(dir, "link")
+	err := os.Symlink("target", link)
+	if err != nil {
+		t.Skipf("os.Symlink() error = %v", err)
+	}
+	for _, content := range []string{"first", "second"} {
+		err = WriteFileAtomic(link, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("WriteFileAtomic() error = %v, want %v", err, nil)
+		}
+		got, err := os.ReadFile(target)
+		if err != nil || string(got) != content {
+			t.Errorf("os.ReadFile(target) = %q, %v, want %q", got, err, content)
+		}
+	}
+	info, err := os.Lstat(link)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("WriteFileAtomic() replaced the symlink (error = %v)", err)
+	}
+}
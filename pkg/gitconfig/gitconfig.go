@@ -1,6 +1,7 @@
 package gitconfig
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"reflect"
@@ -87,12 +88,29 @@ func (k Key) String() string {
 }
 
 // Value represents value of a config variable.
-type Value struct{ v interface{} }
+type Value struct {
+	v        interface{}
+	implicit bool // the variable has no '=', e.g. "[core] bare"
+}
 
 func (val Value) Value() interface{} {
 	return val.v
 }
 
+// Implicit reports whether the variable was written without '=' and so has
+// no value at all, which git treats as true, unlike an empty value.
+func (val Value) Implicit() bool {
+	return val.implicit
+}
+
+// Bool parses the value as a boolean the way git does.
+func (val Value) Bool() (bool, error) {
+	if val.implicit {
+		return true, nil
+	}
+	return ParseBool(val.String())
+}
+
 // ValidateValue validates whether s is a valid value for .gitconfig or not.
 // .gitconfig values can contain any characters and may span multiple lines.
 // '\' indicates that the config value continues on the next line. There MUST NOT be
@@ -119,6 +137,63 @@ func ValidateValue(s string) error {
 	return nil
 }
 
+// encodeValue returns s, a value as given to git config, the way git writes
+// it to a config file: quoted if it has leading or trailing spaces or
+// characters that start a comment, with '\', '"', newlines and tabs escaped.
+// Values with null bytes or carriage returns can't be written.
+func encodeValue(s string) (string, error) {
+	if strings.ContainsAny(s, "\x00\r") {
+		return "", ErrUnencodableValue
+	}
+	var b strings.Builder
+	quote := strings.HasPrefix(s, " ") || strings.HasSuffix(s, " ") || strings.ContainsAny(s, ";#")
+	if quote {
+		b.WriteByte('"')
+	}
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	if quote {
+		b.WriteByte('"')
+	}
+	return b.String(), nil
+}
+
+// newValue returns the Value of v, a value as given to git config, rather
+// than as written in a config file.
+func newValue(v interface{}) (Value, error) {
+	if s, ok := v.(string); ok {
+		encoded, err := encodeValue(s)
+		if err != nil {
+			return Value{}, err
+		}
+		return Value{v: encoded}, nil
+	}
+	values, err := GitConfig{}.isValidValues(v)
+	if err != nil {
+		return Value{}, err
+	}
+	return values[0], nil
+}
+
+// encoded returns val the way git writes it to a config file.
+func (val Value) encoded() (string, error) {
+	if _, ok := val.v.(string); !ok {
+		return fmt.Sprintf("%v", val.v), nil
+	}
+	return encodeValue(val.String())
+}
+
 func (val Value) String() string {
 	var quoted bool
 
@@ -152,6 +227,9 @@ func (val Value) String() string {
 				case 't':
 					ch = '\t'
 					i++
+				case '\n': // the value continues on the next line
+					i++
+					continue
 				}
 			}
 		}
@@ -184,7 +262,7 @@ func (GitConfig) isValidValues(vals ...interface{}) ([]Value, error) {
 		default:
 			return nil, ErrInvalidValueType
 		}
-		values = append(values, Value{vals[i]})
+		values = append(values, Value{v: vals[i]})
 		// type assertion fails to catch local type
 		// switch val.(type) {
 		// case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, string, bool:
@@ -381,33 +459,36 @@ func (g *GitConfig) Unset(key string) error {
 }
 
 // Save writes the current configuration to path.
-// If the file already exists, it will be overwritten.
+// If the file already exists, it will be overwritten. The configuration is
+// written to a temporary file that is renamed to path, so path never holds a
+// partially written configuration.
 func (g GitConfig) Save(path string) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return err
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
 	}
-	defer f.Close()
 
+	var buf bytes.Buffer
 	sections := g.data.keys()
 	for i := range sections {
-		_, err = fmt.Fprintf(f, "%s\n", sections[i])
-		if err != nil {
-			return err
-		}
+		fmt.Fprintf(&buf, "%s\n", sections[i])
 		variables := g.data.mustGet(sections[i]).keys()
 		for j := range variables {
 			values := g.data.mustGet(sections[i]).mustGet(variables[j])
 			for k := range values {
-				_, err = fmt.Fprintf(f, "\t%s = %v\n", variables[j], values[k].Value())
+				if values[k].implicit {
+					fmt.Fprintf(&buf, "\t%s\n", variables[j])
+					continue
+				}
+				value, err := values[k].encoded()
 				if err != nil {
 					return err
 				}
+				fmt.Fprintf(&buf, "\t%s = %s\n", variables[j], value)
 			}
 		}
 	}
-
-	return nil
+	return WriteFileAtomic(path, buf.Bytes(), mode)
 }
 
 // Keys returns slice of all keys in the order they're
@@ -3,7 +3,9 @@ package gitconfig
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"testing"
 )
 
@@ -204,7 +206,7 @@ func TestGitConfig_Get(t *testing.T) {
 			args: args{
 				key: "foo.foo",
 			},
-			want: Value{"foo"},
+			want: Value{v: "foo"},
 		},
 		{
 			name: "Value Non-Existing Key",
@@ -220,7 +222,7 @@ func TestGitConfig_Get(t *testing.T) {
 			args: args{
 				key: "foo.bar",
 			},
-			want: Value{"3"},
+			want: Value{v: "3"},
 		},
 		{
 			name: "Get From Key With Subsection",
@@ -228,7 +230,7 @@ func TestGitConfig_Get(t *testing.T) {
 			args: args{
 				key: "foo.bar.baz",
 			},
-			want: Value{"foo"},
+			want: Value{v: "foo"},
 		},
 		{
 			name: "Get From Key With Nested Sections",
@@ -236,7 +238,7 @@ func TestGitConfig_Get(t *testing.T) {
 			args: args{
 				key: "foo.bar.baz.bla.bla.blu.ble.blo",
 			},
-			want: Value{"foo"},
+			want: Value{v: "foo"},
 		},
 		{
 			name: "Get Non-String Value",
@@ -244,7 +246,7 @@ func TestGitConfig_Get(t *testing.T) {
 			args: args{
 				key: "foo.bool",
 			},
-			want: Value{false},
+			want: Value{v: false},
 		},
 	}
 	for _, tt := range tests {
@@ -278,7 +280,7 @@ func TestGitConfig_GetAll(t *testing.T) {
 			args: args{
 				key: "foo.foo",
 			},
-			want: []Value{{"foo"}},
+			want: []Value{{v: "foo"}},
 		},
 		{
 			name: "Get Key With Multiple Values",
@@ -287,11 +289,11 @@ func TestGitConfig_GetAll(t *testing.T) {
 				key: "foo.bar",
 			},
 			want: []Value{
-				{"foo"},
-				{"bar"},
-				{"1"},
-				{2},
-				{"3"},
+				{v: "foo"},
+				{v: "bar"},
+				{v: "1"},
+				{v: 2},
+				{v: "3"},
 			},
 		},
 		{
@@ -308,7 +310,7 @@ func TestGitConfig_GetAll(t *testing.T) {
 			args: args{
 				key: "foo.bar.baz",
 			},
-			want: []Value{{"foo"}},
+			want: []Value{{v: "foo"}},
 		},
 		{
 			name: "Get From Key With Nested Sections",
@@ -316,7 +318,7 @@ func TestGitConfig_GetAll(t *testing.T) {
 			args: args{
 				key: "foo.bar.baz.bla.bla.blu.ble.blo",
 			},
-			want: []Value{{"foo"}},
+			want: []Value{{v: "foo"}},
 		},
 		{
 			name: "Get Non-String Value",
@@ -324,7 +326,7 @@ func TestGitConfig_GetAll(t *testing.T) {
 			args: args{
 				key: "foo.int",
 			},
-			want: []Value{{1}, {2}, {3}},
+			want: []Value{{v: 1}, {v: 2}, {v: 3}},
 		},
 	}
 	for _, tt := range tests {
@@ -407,6 +409,88 @@ func TestGitConfig_Save(t *testing.T) {
 	}
 }
 
+func TestGitConfig_SaveReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	err := os.WriteFile(path, []byte("[old]\n\tvalue = 1\n"), 0o600)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error = %v, want %v", err, nil)
+	}
+	g := New()
+	if err := g.Set("user.name", "foo"); err != nil {
+		t.Fatalf("GitConfig.Set() error = %v, want %v", err, nil)
+	}
+	if err := g.Save(path); err != nil {
+		t.Fatalf("GitConfig.Save() error = %v, want %v", err, nil)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v, want %v", err, nil)
+	}
+	if want := "[user]\n\tname = foo\n"; string(content) != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("os.Stat() error = %v, want %v", err, nil)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o600))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("os.ReadDir() error = %v, want %v", err, nil)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want 1 (temporary file left behind)", len(entries))
+	}
+}
+
+func TestGitConfig_SaveSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target, link := filepath.Join(dir, "target"), filepath.Join(dir, "link")
+	err := os.Symlink("target", link)
+	if err != nil {
+		t.Skipf("os.Symlink() error = %v", err)
+	}
+	g := New()
+	if err := g.Set("user.name", "foo"); err != nil {
+		t.Fatalf("GitConfig.Set() error = %v, want %v", err, nil)
+	}
+	if err := g.Save(link); err != nil {
+		t.Fatalf("GitConfig.Save() error = %v, want %v", err, nil)
+	}
+
+	content, err := os.ReadFile(target)
+	if want := "[user]\n\tname = foo\n"; err != nil || string(content) != want {
+		t.Errorf("os.ReadFile(target) = (%q, %v), want (%q, %v)", content, err, want, nil)
+	}
+	info, err := os.Lstat(link)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("GitConfig.Save() replaced the symlink (error = %v)", err)
+	}
+}
+
+func TestGitConfig_SaveQuotesValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	g := New()
+	if err := g.Set("credential.helper", "!f() { cat; }; f"); err != nil {
+		t.Fatalf("GitConfig.Set() error = %v, want %v", err, nil)
+	}
+	if err := g.Save(path); err != nil {
+		t.Fatalf("GitConfig.Save() error = %v, want %v", err, nil)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v, want %v", err, nil)
+	}
+	if want := "[credential]\n\thelper = \"!f() { cat; }; f\"\n"; string(content) != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+}
+
 func TestGitConfig(t *testing.T) {
 	gitConfig := New()
 	err := gitConfig.Set("foo.bar", "boo")
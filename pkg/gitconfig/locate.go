@@ -0,0 +1,265 @@
+package gitconfig
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repository holds the locations git uses for a repository.
+type Repository struct {
+	GitDir       string // git directory of the current work tree
+	CommonDir    string // git directory shared by all work trees of the repository
+	WorkTree     string // top-level directory of the work tree, empty for bare repositories
+	InsideGitDir bool   // the directory FindRepository was called with is inside GitDir
+}
+
+// FindRepository finds the repository dir belongs to, the same way git does:
+// $GIT_DIR if it's set, otherwise the first of dir and its parents that either
+// contains a .git directory (or a .git file pointing to one) or is a git directory
+// itself. It returns ErrNoRepository if there's none.
+func FindRepository(dir string) (Repository, error) {
+	var repo Repository
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Repository{}, err
+	}
+
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		repo.GitDir, err = filepath.Abs(gitDir)
+		if err != nil {
+			return Repository{}, err
+		}
+		repo.CommonDir = commonDir(repo.GitDir)
+		bare, err := repo.isBare()
+		if err != nil {
+			return Repository{}, err
+		}
+		if workTree := os.Getenv("GIT_WORK_TREE"); workTree != "" {
+			repo.WorkTree, err = filepath.Abs(workTree)
+			if err != nil {
+				return Repository{}, err
+			}
+		} else if !bare {
+			repo.WorkTree = dir
+		}
+		return repo, nil
+	}
+
+	for d := dir; ; d = filepath.Dir(d) {
+		dotGit := filepath.Join(d, ".git")
+		info, err := os.Stat(dotGit)
+		switch {
+		case err == nil && info.IsDir() && isGitDir(dotGit):
+			repo.GitDir, repo.WorkTree = dotGit, d
+		case err == nil && info.Mode().IsRegular():
+			repo.GitDir, err = readGitFile(dotGit)
+			if err != nil {
+				return Repository{}, err
+			}
+			repo.WorkTree = d
+		case isGitDir(d):
+			repo.GitDir, repo.InsideGitDir = d, true
+		}
+		if repo.GitDir != "" {
+			break
+		}
+		if filepath.Dir(d) == d {
+			return Repository{}, ErrNoRepository
+		}
+	}
+	repo.CommonDir = commonDir(repo.GitDir)
+
+	if repo.InsideGitDir {
+		bare, err := repo.isBare()
+		if err != nil {
+			return Repository{}, err
+		}
+		if !bare {
+			repo.WorkTree = workTreeOf(repo.GitDir)
+		}
+	}
+
+	return repo, nil
+}
+
+// IsBare reports whether the repository has no work tree.
+func (r Repository) IsBare() bool {
+	return r.WorkTree == ""
+}
+
+// ConfigPath returns the path of the repository config (git config --local).
+func (r Repository) ConfigPath() string {
+	return filepath.Join(r.CommonDir, "config")
+}
+
+// WorktreeConfigPath returns the path of the config of the current work tree
+// (git config --worktree) when extensions.worktreeConfig is enabled.
+func (r Repository) WorktreeConfigPath() string {
+	return filepath.Join(r.GitDir, "config.worktree")
+}
+
+// WorktreeConfigEnabled reports whether extensions.worktreeConfig is enabled.
+func (r Repository) WorktreeConfigEnabled() (bool, error) {
+	return getBool(r.ConfigPath(), "extensions.worktreeConfig")
+}
+
+func (r Repository) isBare() (bool, error) {
+	return getBool(filepath.Join(r.CommonDir, "config"), "core.bare")
+}
+
+// GlobalConfigPath returns the path git writes the global config to:
+// $GIT_CONFIG_GLOBAL if it's set, $XDG_CONFIG_HOME/git/config if it's the
+// only one that exists, ~/.gitconfig otherwise.
+func GlobalConfigPath() (string, error) {
+	if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	homeConfig := filepath.Join(homeDir, ".gitconfig")
+	xdgConfig, err := xdgConfigPath()
+	if err != nil {
+		return "", err
+	}
+	if !exists(homeConfig) && exists(xdgConfig) {
+		return xdgConfig, nil
+	}
+	return homeConfig, nil
+}
+
+// GlobalConfigPaths returns the paths git reads the global config from, in the
+// order it reads them. Some of them may not exist.
+func GlobalConfigPaths() ([]string, error) {
+	if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+		return []string{path}, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	xdgConfig, err := xdgConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return []string{xdgConfig, filepath.Join(homeDir, ".gitconfig")}, nil
+}
+
+// SystemConfigPath returns the path of the system config, or an empty string
+// if $GIT_CONFIG_NOSYSTEM disables it. Without $GIT_CONFIG_SYSTEM, the real
+// location depends on the prefix git was built with, so this is only the
+// common one.
+func SystemConfigPath() string {
+	if noSystem := os.Getenv("GIT_CONFIG_NOSYSTEM"); noSystem != "" {
+		if disabled, err := ParseBool(noSystem); err == nil && disabled {
+			return ""
+		}
+	}
+	if path := os.Getenv("GIT_CONFIG_SYSTEM"); path != "" {
+		return path
+	}
+	return "/etc/gitconfig"
+}
+
+func xdgConfigPath() (string, error) {
+	if xdgHome := os.Getenv("XDG_CONFIG_HOME"); xdgHome != "" {
+		return filepath.Join(xdgHome, "git", "config"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config", "git", "config"), nil
+}
+
+// isGitDir reports whether dir looks like a git directory.
+func isGitDir(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if exists(filepath.Join(dir, "commondir")) {
+		return true
+	}
+	return isDir(filepath.Join(dir, "objects")) && isDir(filepath.Join(dir, "refs"))
+}
+
+// readGitFile reads a .git file ("gitdir: <path>") as used by linked work trees
+// and submodules, and returns the git directory it points to.
+func readGitFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+	if !ok {
+		return "", ErrNoRepository
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// commonDir returns the git directory shared by all work trees of gitDir.
+func commonDir(gitDir string) string {
+	content, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	dir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitDir, dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// workTreeOf returns the work tree of a non-bare git directory.
+func workTreeOf(gitDir string) string {
+	if content, err := os.ReadFile(filepath.Join(gitDir, "gitdir")); err == nil { // linked work tree
+		return filepath.Dir(strings.TrimSpace(string(content)))
+	}
+	return filepath.Dir(gitDir)
+}
+
+// getBool reads a boolean variable from the config file at path. A missing
+// file or variable is false.
+func getBool(path, key string) (bool, error) {
+	f, err := OpenFile(path)
+	if err != nil {
+		return false, err
+	}
+	values, err := f.GetAll(key)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return values[len(values)-1].Bool()
+}
+
+// ParseBool parses a boolean value the way git does. An empty value is
+// false; a variable without any value is true, see Value.Bool.
+func ParseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+	return false, ErrInvalidBool
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,198 @@
+package gitconfig
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=foo", "GIT_AUTHOR_EMAIL=foo@example.com", "GIT_COMMITTER_NAME=foo", "GIT_COMMITTER_EMAIL=foo@example.com")
+	gitOutput, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s error = %v: %s", strings.Join(args, " "), err, gitOutput)
+	}
+	return strings.TrimSpace(string(gitOutput))
+}
+
+func TestFindRepository(t *testing.T) {
+	t.Setenv("GIT_DIR", "") // restored after the test
+	os.Unsetenv("GIT_DIR")
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("filepath.EvalSymlinks() error = %v, want %v", err, nil)
+	}
+	main := filepath.Join(root, "main")
+	runGit(t, root, "init", "-q", "main")
+	runGit(t, main, "commit", "-q", "--allow-empty", "-m", "init")
+	runGit(t, main, "worktree", "add", "-q", filepath.Join(root, "linked"))
+	runGit(t, root, "clone", "-q", "--bare", "main", "bare.git")
+	err = os.MkdirAll(filepath.Join(main, "sub", "dir"), 0o755)
+	if err != nil {
+		t.Fatalf("os.MkdirAll() error = %v, want %v", err, nil)
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		want Repository
+	}{
+		{
+			name: "Work Tree Subdirectory",
+			dir:  filepath.Join(main, "sub", "dir"),
+			want: Repository{GitDir: filepath.Join(main, ".git"), CommonDir: filepath.Join(main, ".git"), WorkTree: main},
+		},
+		{
+			name: "Inside Git Directory",
+			dir:  filepath.Join(main, ".git", "refs"),
+			want: Repository{GitDir: filepath.Join(main, ".git"), CommonDir: filepath.Join(main, ".git"), WorkTree: main, InsideGitDir: true},
+		},
+		{
+			name: "Linked Work Tree",
+			dir:  filepath.Join(root, "linked"),
+			want: Repository{GitDir: filepath.Join(main, ".git", "worktrees", "linked"), CommonDir: filepath.Join(main, ".git"), WorkTree: filepath.Join(root, "linked")},
+		},
+		{
+			name: "Bare Repository",
+			dir:  filepath.Join(root, "bare.git"),
+			want: Repository{GitDir: filepath.Join(root, "bare.git"), CommonDir: filepath.Join(root, "bare.git"), InsideGitDir: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindRepository(tt.dir)
+			if err != nil {
+				t.Fatalf("FindRepository() error = %v, want %v", err, nil)
+			}
+			if got != tt.want {
+				t.Errorf("FindRepository() = %+v, want %+v", got, tt.want)
+			}
+			if gitDir := runGit(t, tt.dir, "rev-parse", "--absolute-git-dir"); got.GitDir != gitDir {
+				t.Errorf("FindRepository().GitDir = %s, git says %s", got.GitDir, gitDir)
+			}
+		})
+	}
+
+	_, err = FindRepository(root)
+	if err != ErrNoRepository {
+		t.Errorf("FindRepository(%s) error = %v, want %v", root, err, ErrNoRepository)
+	}
+}
+
+func TestGlobalConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", "")
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+	homeConfig := filepath.Join(home, ".gitconfig")
+	xdgConfig := filepath.Join(home, "xdg", "git", "config")
+
+	got, err := GlobalConfigPath()
+	if err != nil || got != homeConfig {
+		t.Errorf("GlobalConfigPath() = (%v, %v), want (%v, %v)", got, err, homeConfig, nil)
+	}
+
+	err = os.MkdirAll(filepath.Dir(xdgConfig), 0o755)
+	if err != nil {
+		t.Fatalf("os.MkdirAll() error = %v, want %v", err, nil)
+	}
+	err = os.WriteFile(xdgConfig, nil, 0o644)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error = %v, want %v", err, nil)
+	}
+	got, err = GlobalConfigPath()
+	if err != nil || got != xdgConfig {
+		t.Errorf("GlobalConfigPath() = (%v, %v), want (%v, %v)", got, err, xdgConfig, nil)
+	}
+}
+
+func TestGlobalConfigPath_GitConfigGlobal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "custom.gitconfig")
+	t.Setenv("GIT_CONFIG_GLOBAL", path)
+
+	got, err := GlobalConfigPath()
+	if err != nil || got != path {
+		t.Errorf("GlobalConfigPath() = (%v, %v), want (%v, %v)", got, err, path, nil)
+	}
+	paths, err := GlobalConfigPaths()
+	if err != nil || len(paths) != 1 || paths[0] != path {
+		t.Errorf("GlobalConfigPaths() = (%v, %v), want (%v, %v)", paths, err, []string{path}, nil)
+	}
+}
+
+func TestSystemConfigPath(t *testing.T) {
+	tests := []struct {
+		name, noSystem, system, want string
+	}{
+		{name: "Default", want: "/etc/gitconfig"},
+		{name: "GIT_CONFIG_SYSTEM", system: "/opt/git/etc/gitconfig", want: "/opt/git/etc/gitconfig"},
+		{name: "GIT_CONFIG_NOSYSTEM", noSystem: "1", system: "/opt/git/etc/gitconfig"},
+		{name: "GIT_CONFIG_NOSYSTEM False", noSystem: "false", want: "/etc/gitconfig"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GIT_CONFIG_NOSYSTEM", tt.noSystem)
+			t.Setenv("GIT_CONFIG_SYSTEM", tt.system)
+			if got := SystemConfigPath(); got != tt.want {
+				t.Errorf("SystemConfigPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValue_Bool(t *testing.T) {
+	content := "[core]\n\tbare\n\tempty =\n\tyes = yes\n\toff = off\n\tbad = maybe\n"
+	tests := []struct {
+		key     string
+		want    bool
+		wantErr error
+	}{
+		{"core.bare", true, nil},
+		{"core.empty", false, nil},
+		{"core.yes", true, nil},
+		{"core.off", false, nil},
+		{"core.bad", false, ErrInvalidBool},
+	}
+	config, err := Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("Parse() error = %v, want %v", err, nil)
+	}
+	f, err := ParseFile("config", []byte(content))
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v, want %v", err, nil)
+	}
+	for _, tt := range tests {
+		fileValues, err := f.GetAll(tt.key)
+		if err != nil {
+			t.Fatalf("File.GetAll(%s) error = %v, want %v", tt.key, err, nil)
+		}
+		configValue, err := config.Get(tt.key)
+		if err != nil {
+			t.Fatalf("GitConfig.Get(%s) error = %v, want %v", tt.key, err, nil)
+		}
+		for _, value := range []Value{fileValues[0], configValue} {
+			got, err := value.Bool()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: Value.Bool() error = %v, want %v", tt.key, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("%s: Value.Bool() = %v, want %v", tt.key, got, tt.want)
+			}
+		}
+	}
+
+	// an implicit value stays one when saved
+	path := filepath.Join(t.TempDir(), "config")
+	if err := config.Save(path); err != nil {
+		t.Fatalf("GitConfig.Save() error = %v, want %v", err, nil)
+	}
+	if got, err := getBool(path, "core.bare"); err != nil || !got {
+		t.Errorf("getBool(core.bare) after Save() = %v, %v, want %v", got, err, true)
+	}
+}
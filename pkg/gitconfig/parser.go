@@ -147,7 +147,7 @@ loop:
 				}
 			}
 		case variable:
-			name, err := c.parseVariable()
+			name, implicit, err := c.parseVariable()
 			if err != nil {
 				return nil, &ParseError{
 					Err:        err,
@@ -155,7 +155,7 @@ loop:
 					LineNumber: c.cline,
 				}
 			}
-			gc.add(sec, name, Value{string(c.buff)})
+			gc.add(sec, name, Value{v: string(c.buff), implicit: implicit})
 		case comment, end:
 			err = c.toEndOfLine()
 			if err != nil {
@@ -233,8 +233,10 @@ func (c *configFile) parseSubsection() error {
 	return nil
 }
 
-func (c *configFile) parseVariable() (VariableName, error) {
-	var spaceFound bool
+// parseVariable parses a variable into its name and c.buff, its raw value. It
+// also reports whether the variable has no '=', i.e. no value at all.
+func (c *configFile) parseVariable() (VariableName, bool, error) {
+	var spaceFound, equalsFound, lineEnded bool
 	c.buff = c.buff[:0]
 	for { // parse variable name, only allows alphanumeric and '-'
 		ch, err := c.readCh()
@@ -242,9 +244,17 @@ func (c *configFile) parseVariable() (VariableName, error) {
 			break
 		}
 		if spaceFound && (isAlnum(ch) || ch == '-') {
-			return "", ErrInvalidVariableName
+			return "", false, ErrInvalidVariableName
 		}
 		if ch == '=' {
+			equalsFound = true
+			break
+		}
+		if ch == '\n' || ch == ';' || ch == '#' { // a variable without a value
+			if ch != '\n' {
+				_ = c.toEndOfLine()
+			}
+			lineEnded = true
 			break
 		}
 		if unicode.IsSpace(rune(ch)) {
@@ -252,23 +262,29 @@ func (c *configFile) parseVariable() (VariableName, error) {
 			continue
 		}
 		if !isAlnum(ch) && ch != '-' {
-			return "", ErrInvalidVariableName
+			return "", false, ErrInvalidVariableName
 		}
 		c.buff = append(c.buff, ch)
 	}
 	name := VariableName(string(c.buff))
 	if !name.isValid() {
-		return "", ErrInvalidVariableName
+		return "", false, ErrInvalidVariableName
+	}
+	c.buff = c.buff[:0]
+	if lineEnded {
+		return name, true, nil
+	}
+	for c.nextCh() == ' ' || c.nextCh() == '\t' { // but not past the end of an empty value
+		_, _ = c.readCh()
 	}
-	c.trimSpaceLeft()
 
 	err := c.parseValue()
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	c.removeCarriageReturn()
-	return name, nil
+	return name, !equalsFound, nil
 }
 
 // allow any chars, '\' denotes value continues on the next line
@@ -0,0 +1,316 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+// useExecFallback reports whether err means the config has to be handled by
+// the git executable instead, because the native implementation can't.
+func useExecFallback(err error) bool {
+	var parseErr *gitconfig.ParseError
+	return errors.Is(err, errNativeUnsupported) || errors.Is(err, gitconfig.ErrUnencodableValue) || errors.As(err, &parseErr)
+}
+
+// runGit runs git with args in the directory of m, writing its output to
+// the Stderr of m if it fails with a status other than okStatus.
+func (m *Manager) runGit(ctx context.Context, okStatus int, args ...string) error {
+	cmd := m.Command(ctx, args...)
+	gitOutput, err := cmd.CombinedOutput()
+	if err != nil && (cmd.ProcessState == nil || cmd.ProcessState.ExitCode() != okStatus) {
+		fmt.Fprintf(m.stderr, "git: %s", string(gitOutput))
+		return err
+	}
+	return nil
+}
+
+// UnsetIncludes removes the includes matching pattern from the config file
+// of the given scope.
+func (m *Manager) UnsetIncludes(ctx context.Context, pattern string, scope Scope) error {
+	if !m.gitExec {
+		path, err := m.configFilePath(ctx, scope)
+		if err == nil {
+			err = nativeUnset("include.path", pattern, path)
+		}
+		if !useExecFallback(err) {
+			return err
+		}
+	}
+	// trying to unset an option that doesn't exist gives exit 5
+	return m.runGit(ctx, 5, "config", scope.Flag(), "--unset-all", "include.path", pattern)
+}
+
+// UnsetIncludesFile is UnsetIncludes for the config file at path.
+func (m *Manager) UnsetIncludesFile(ctx context.Context, pattern, path string) error {
+	if !m.gitExec {
+		err := nativeUnset("include.path", pattern, path)
+		if !useExecFallback(err) {
+			return err
+		}
+	}
+	return m.runGit(ctx, 5, "config", "--file", path, "--unset-all", "include.path", pattern)
+}
+
+// applyInclude replaces the profile includes of the config file of the given
+// scope with one of configPath.
+func (m *Manager) applyInclude(ctx context.Context, configPath string, scope Scope) error {
+	if !m.gitExec {
+		path, err := m.configFilePath(ctx, scope)
+		if err == nil {
+			err = nativeReplace("include.path", configPath, m.includePattern.String(), path)
+		}
+		if !useExecFallback(err) {
+			return err
+		}
+	}
+	return m.runGit(ctx, 0, "config", scope.Flag(), "--replace-all", "include.path", configPath, m.includePattern.String())
+}
+
+// ReplaceIncludesFile replaces the includes matching pattern in the config
+// file at path with one of configPath.
+func (m *Manager) ReplaceIncludesFile(ctx context.Context, configPath, pattern, path string) error {
+	if !m.gitExec {
+		err := nativeReplace("include.path", configPath, pattern, path)
+		if !useExecFallback(err) {
+			return err
+		}
+	}
+	return m.runGit(ctx, 0, "config", "--file", path, "--replace-all", "include.path", configPath, pattern)
+}
+
+// GetAll returns the values of key in the config file at path.
+func (m *Manager) GetAll(ctx context.Context, key, path string) ([]string, error) {
+	if !m.gitExec {
+		values, err := nativeGetAll(key, path)
+		if !useExecFallback(err) {
+			return values, err
+		}
+	}
+	cmd := m.Command(ctx, "config", "--file", path, "--get-all", key)
+	gitOutput, err := cmd.Output()
+	if err != nil {
+		if cmd.ProcessState != nil && cmd.ProcessState.ExitCode() == 1 { // key not set
+			return nil, nil
+		}
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(string(gitOutput)), "\n"), nil
+}
+
+// Set sets key to value in the config file at path, replacing all of its
+// values.
+func (m *Manager) Set(ctx context.Context, key, value, path string) error {
+	if !m.gitExec {
+		err := nativeReplace(key, value, "", path)
+		if !useExecFallback(err) {
+			return err
+		}
+	}
+	return m.runGit(ctx, 0, "config", "--file", path, "--replace-all", key, value)
+}
+
+// SetAll sets key to values in the config file at path, replacing all of its
+// values.
+func (m *Manager) SetAll(ctx context.Context, key string, values []string, path string) error {
+	if !m.gitExec {
+		err := nativeSetAll(key, values, path)
+		if !useExecFallback(err) {
+			return err
+		}
+	}
+	err := m.runGit(ctx, 5, "config", "--file", path, "--unset-all", key)
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		err = m.runGit(ctx, 0, "config", "--file", path, "--add", key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unset removes all values of key from the config file at path.
+func (m *Manager) Unset(ctx context.Context, key, path string) error {
+	if !m.gitExec {
+		err := nativeUnset(key, "", path)
+		if !useExecFallback(err) {
+			return err
+		}
+	}
+	return m.runGit(ctx, 5, "config", "--file", path, "--unset-all", key)
+}
+
+// currentInclude returns the profile include of the given scope. Without an
+// explicit scope, the include that takes effect is returned, which is the
+// last one git reads.
+func (m *Manager) currentInclude(ctx context.Context, scope Scope) (string, error) {
+	if !m.gitExec {
+		include, err := m.nativeCurrentInclude(ctx, scope)
+		if !useExecFallback(err) {
+			return include, err
+		}
+	}
+	var cmd *exec.Cmd
+	if scope == "" {
+		cmd = m.Command(ctx, "config", "--get-all", "include.path", m.includePattern.String())
+	} else {
+		err := m.checkScope(ctx, scope)
+		if err != nil {
+			return "", err
+		}
+		cmd = m.Command(ctx, "config", scope.Flag(), "--get-all", "include.path", m.includePattern.String())
+	}
+	gitOutput, err := cmd.Output()
+	if err != nil && cmd.ProcessState.ExitCode() != 1 {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			fmt.Fprintf(m.stderr, "git: %s", string(exitErr.Stderr))
+		}
+		return "", err
+	}
+	includes := strings.Split(strings.TrimSpace(string(gitOutput)), "\n")
+	return includes[len(includes)-1], nil
+}
+
+func (m *Manager) nativeCurrentInclude(ctx context.Context, scope Scope) (string, error) {
+	var paths []string
+	if scope != "" {
+		path, err := m.configFilePath(ctx, scope)
+		if err != nil {
+			return "", err
+		}
+		paths = append(paths, path)
+	} else {
+		globalPaths, err := gitconfig.GlobalConfigPaths()
+		if err != nil {
+			return "", err
+		}
+		if systemPath := gitconfig.SystemConfigPath(); systemPath != "" {
+			paths = append(paths, systemPath)
+		}
+		paths = append(paths, globalPaths...)
+		repo, ok, err := m.Repository(ctx)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			paths = append(paths, filepath.Join(repo.CommonDir, "config"))
+			if repo.WorktreeConfig {
+				paths = append(paths, filepath.Join(repo.GitDir, "config.worktree"))
+			}
+		}
+	}
+
+	var currentInclude string
+	for _, path := range paths {
+		f, err := gitconfig.OpenFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrPermission) {
+				continue
+			}
+			return "", err
+		}
+		includes, err := f.GetAll("include.path")
+		if err != nil && !errors.Is(err, gitconfig.ErrKeyNotFound) {
+			return "", err
+		}
+		for _, include := range includes {
+			if m.includePattern.MatchString(include.String()) {
+				currentInclude = include.String()
+			}
+		}
+	}
+
+	return currentInclude, nil
+}
+
+// compileValuePattern compiles the value pattern of a git config command,
+// where an empty pattern matches every value.
+func compileValuePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+func nativeUnset(key, pattern, path string) error {
+	valuePattern, err := compileValuePattern(pattern)
+	if err != nil {
+		return err
+	}
+	f, err := gitconfig.LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Unlock()
+	n, err := f.UnsetAll(key, valuePattern)
+	if err != nil || n == 0 {
+		return err
+	}
+	return f.Commit()
+}
+
+func nativeReplace(key, value, pattern, path string) error {
+	valuePattern, err := compileValuePattern(pattern)
+	if err != nil {
+		return err
+	}
+	f, err := gitconfig.LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Unlock()
+	err = f.ReplaceAll(key, value, valuePattern)
+	if err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+func nativeSetAll(key string, values []string, path string) error {
+	f, err := gitconfig.LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Unlock()
+	_, err = f.UnsetAll(key, nil)
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		err = f.Add(key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return f.Commit()
+}
+
+func nativeGetAll(key, path string) ([]string, error) {
+	f, err := gitconfig.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values, err := f.GetAll(key)
+	if err != nil {
+		if errors.Is(err, gitconfig.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	strs := make([]string, len(values))
+	for i := range values {
+		strs[i] = values[i].String()
+	}
+	return strs, nil
+}
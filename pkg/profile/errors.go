@@ -0,0 +1,17 @@
+package profile
+
+import "errors"
+
+var (
+	ErrEmptyName              = errors.New("profile name can't be empty")
+	ErrDuplicateProfile       = errors.New("profile with given name already exists")
+	ErrProfileNotFound        = errors.New("profile not found")
+	ErrDefaultProfile         = errors.New("the default profile is the global config, not a stored profile")
+	ErrNotGitDirectory        = errors.New("not in a git directory")
+	ErrWorktreeConfigDisabled = errors.New("--scope worktree needs extensions.worktreeConfig to be enabled when there are multiple work trees")
+	ErrRelativeStorageDir     = errors.New("storage directory must be an absolute path")
+)
+
+// errNativeUnsupported is returned by the native config functions for
+// cases they can't handle, in which case the git executable is used instead.
+var errNativeUnsupported = errors.New("not supported without the git executable")
@@ -0,0 +1,416 @@
+// Package profile stores git profiles and switches between them, the way
+// git-sw does. A profile is a git config file that is included in the config
+// of a repository, or in the global config, by an include.path.
+package profile
+
+import (
+	"cmp"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+// DefaultName is the name of the profile that stands for the global config.
+// Using it removes the profile include instead of adding one.
+const DefaultName = "default"
+
+// backupDirName is the directory git-sw keeps deleted profiles in, which
+// List skips.
+const backupDirName = "backups"
+
+// Profile is a profile stored by a Manager.
+type Profile struct {
+	Config        *gitconfig.GitConfig // only set by Get and the methods that load it
+	Name, DirName string
+	IsActive      bool // used in the scope given to List
+}
+
+// Options configures a Manager.
+type Options struct {
+	// StorageDir is the absolute path of the directory profiles are stored
+	// in. The includes of profiles are recognised by its name.
+	StorageDir string
+	// Dir is the directory the repository scopes refer to, the current
+	// directory if empty.
+	Dir string
+	// GitExec makes the Manager read and write git config through the git
+	// executable, instead of editing the config files directly.
+	GitExec bool
+	// Git runs git, ExecRunner if nil.
+	Git GitRunner
+	// Stderr receives the output of git when it fails. It's discarded if nil.
+	Stderr io.Writer
+}
+
+// Manager creates, lists, uses, edits and deletes the profiles stored in a
+// directory.
+type Manager struct {
+	storageDir, dir string
+	gitExec         bool
+	gitRunner       GitRunner
+	stderr          io.Writer
+	includePattern  *regexp.Regexp
+}
+
+// New returns a Manager for the profiles in opts.StorageDir, which doesn't
+// have to exist yet.
+func New(opts Options) (*Manager, error) {
+	if !filepath.IsAbs(opts.StorageDir) {
+		return nil, ErrRelativeStorageDir
+	}
+	storageDir := filepath.Clean(opts.StorageDir)
+	return &Manager{
+		storageDir:     storageDir,
+		dir:            opts.Dir,
+		gitExec:        opts.GitExec,
+		gitRunner:      cmp.Or[GitRunner](opts.Git, ExecRunner{}),
+		stderr:         cmp.Or[io.Writer](opts.Stderr, io.Discard),
+		includePattern: regexp.MustCompile(storageName(storageDir) + ".*gitconfig$"),
+	}, nil
+}
+
+// DirName returns the name of the directory the profile with the given name
+// is stored in.
+func DirName(name string) string {
+	sum := md5.Sum([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+// storageName is the name of the directory profiles are stored in, quoted
+// for a regular expression. The includes of profiles are recognised by it.
+func storageName(storageDir string) string {
+	return regexp.QuoteMeta(filepath.Base(storageDir))
+}
+
+// StorageDir returns the directory profiles are stored in.
+func (m *Manager) StorageDir() string {
+	return m.storageDir
+}
+
+// IncludePattern matches the include.path of every profile.
+func (m *Manager) IncludePattern() *regexp.Regexp {
+	return m.includePattern
+}
+
+// ProfileIncludePattern matches the include.path of the profile stored in dirName.
+func (m *Manager) ProfileIncludePattern(dirName string) string {
+	return storageName(m.storageDir) + `.*` + dirName + `.\.gitconfig$`
+}
+
+// Path returns the directory the profile with the given name is stored in.
+func (m *Manager) Path(name string) string {
+	return filepath.Join(m.storageDir, DirName(name))
+}
+
+// ConfigPath returns the path of the config file of a profile. The default
+// profile isn't a copy, it's the global config itself.
+func (m *Manager) ConfigPath(p Profile) (string, error) {
+	if p.Name == DefaultName {
+		return gitconfig.GlobalConfigPath()
+	}
+	return filepath.Join(m.storageDir, p.DirName, ".gitconfig"), nil
+}
+
+// LoadConfig reads the config of a profile.
+func (m *Manager) LoadConfig(p Profile) (*gitconfig.GitConfig, error) {
+	configPath, err := m.ConfigPath(p)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		if p.Name == DefaultName && errors.Is(err, os.ErrNotExist) {
+			return gitconfig.New(), nil
+		}
+		return nil, err
+	}
+	return gitconfig.Parse(content)
+}
+
+// Current returns the name of the profile used in the given scope, or the one
+// that takes effect if scope is empty. It's DefaultName if no profile is used.
+func (m *Manager) Current(ctx context.Context, scope Scope) (string, error) {
+	currentInclude, err := m.currentInclude(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+	profileDir := filepath.Dir(currentInclude)
+	if profileDir == "." {
+		return DefaultName, nil
+	}
+	profileName, err := os.ReadFile(filepath.Join(profileDir, "profile"))
+	if err != nil {
+		return DefaultName, err
+	}
+	return string(profileName), nil
+}
+
+// ListOptions are the options of List.
+type ListOptions struct {
+	Scope Scope // marks the profile used in it as active, see Current
+}
+
+// List returns the default profile and the stored ones, sorted by name.
+// Directories whose profile file doesn't match their name are skipped.
+func (m *Manager) List(ctx context.Context, opts ListOptions) ([]Profile, error) {
+	currProfile, err := m.Current(ctx, opts.Scope)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	profiles, err := m.stored()
+	if err != nil {
+		return nil, err
+	}
+	for i := range profiles {
+		profiles[i].IsActive = profiles[i].Name == currProfile
+	}
+	return profiles, nil
+}
+
+// stored returns the default profile and the stored ones, sorted by name.
+func (m *Manager) stored() ([]Profile, error) {
+	defaultDirName := DirName(DefaultName)
+	profiles := []Profile{{Name: DefaultName, DirName: defaultDirName}}
+
+	err := filepath.WalkDir(m.storageDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == m.storageDir && errors.Is(err, fs.ErrNotExist) { // nothing has been saved yet
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() && d.Name() == defaultDirName { // snapshot of the global config made by older versions
+			return fs.SkipDir
+		}
+		if d.IsDir() && path == filepath.Join(m.storageDir, backupDirName) {
+			return fs.SkipDir
+		}
+		if d.Name() == "profile" {
+			profileName, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			dirName := filepath.Base(filepath.Dir(path))
+			if DirName(string(profileName)) != dirName { // renamed by something else than git-sw
+				return fs.SkipDir
+			}
+			profiles = append(profiles, Profile{Name: string(profileName), DirName: dirName})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(profiles, func(a, b Profile) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return profiles, nil
+}
+
+// Get returns the profile with the given name, ignoring case, with its
+// config loaded.
+func (m *Manager) Get(ctx context.Context, name string) (Profile, error) {
+	p, err := m.find(name)
+	if err != nil {
+		return Profile{}, err
+	}
+	p.Config, err = m.LoadConfig(p)
+	if err != nil {
+		return Profile{}, err
+	}
+	return p, nil
+}
+
+// find returns the profile with the given name, ignoring case.
+func (m *Manager) find(name string) (Profile, error) {
+	profiles, err := m.stored()
+	if err != nil {
+		return Profile{}, err
+	}
+	for _, p := range profiles {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+	return Profile{}, ErrProfileNotFound
+}
+
+// ValidateName checks that a profile can be created, or renamed to, name.
+// Profile names are unique regardless of case.
+func (m *Manager) ValidateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return ErrEmptyName
+	}
+	_, err := m.find(name)
+	if err == nil {
+		return ErrDuplicateProfile
+	}
+	if !errors.Is(err, ErrProfileNotFound) {
+		return err
+	}
+	return nil
+}
+
+// CreateOptions are the options of Create.
+type CreateOptions struct {
+	Name   string
+	Config *gitconfig.GitConfig // empty if nil
+}
+
+// Create stores a new profile.
+func (m *Manager) Create(ctx context.Context, opts CreateOptions) (Profile, error) {
+	err := m.ValidateName(opts.Name)
+	if err != nil {
+		return Profile{}, err
+	}
+	config := opts.Config
+	if config == nil {
+		config = gitconfig.New()
+	}
+	err = m.save(opts.Name, config)
+	if err != nil {
+		return Profile{}, err
+	}
+	return Profile{Config: config, Name: opts.Name, DirName: DirName(opts.Name)}, nil
+}
+
+// save writes the directory of a profile, which is removed again if that fails.
+func (m *Manager) save(name string, config *gitconfig.GitConfig) (err error) {
+	dirPath := m.Path(name)
+	err = os.MkdirAll(dirPath, 0o744)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, os.RemoveAll(dirPath))
+		}
+	}()
+	err = config.Save(filepath.Join(dirPath, ".gitconfig"))
+	if err != nil {
+		return err
+	}
+	return gitconfig.WriteFileAtomic(filepath.Join(dirPath, "profile"), []byte(name), 0o444)
+}
+
+// UseOptions are the options of Use.
+type UseOptions struct {
+	Scope Scope // see UseScope
+}
+
+// Use makes the profile with the given name the one of a scope, and returns
+// the scope. Using DefaultName removes the profile include, so only the
+// global config is left.
+func (m *Manager) Use(ctx context.Context, name string, opts UseOptions) (Scope, error) {
+	scope, err := m.UseScope(ctx, opts.Scope)
+	if err != nil {
+		return "", err
+	}
+	p, err := m.find(name)
+	if err != nil {
+		return "", err
+	}
+	if p.Name == DefaultName {
+		return scope, m.UnsetIncludes(ctx, storageName(m.storageDir)+`.*\.gitconfig$`, scope)
+	}
+	configPath, err := m.ConfigPath(p)
+	if err != nil {
+		return "", err
+	}
+	return scope, m.applyInclude(ctx, configPath, scope)
+}
+
+// EditOptions are the options of Edit.
+type EditOptions struct {
+	// Edit changes the config file at path, e.g. by opening it in an editor.
+	Edit func(ctx context.Context, path string) error
+}
+
+// Edit lets opts.Edit change the config of the profile with the given name.
+func (m *Manager) Edit(ctx context.Context, name string, opts EditOptions) error {
+	p, err := m.find(name)
+	if err != nil {
+		return err
+	}
+	if p.Name == DefaultName {
+		return ErrDefaultProfile
+	}
+	configPath, err := m.ConfigPath(p)
+	if err != nil {
+		return err
+	}
+	return opts.Edit(ctx, configPath)
+}
+
+// DeleteOptions are the options of Delete.
+type DeleteOptions struct {
+	Scope Scope // the include is removed from the scopes of WriteScopes
+	// KeepFiles leaves the directory of the profile in place, e.g. so the
+	// caller can back it up.
+	KeepFiles bool
+}
+
+// Delete removes the include of the profile with the given name, then the
+// profile itself.
+func (m *Manager) Delete(ctx context.Context, name string, opts DeleteOptions) error {
+	p, err := m.find(name)
+	if err != nil {
+		return err
+	}
+	if p.Name == DefaultName {
+		return ErrDefaultProfile
+	}
+	scopes, err := m.WriteScopes(ctx, opts.Scope)
+	if err != nil {
+		return err
+	}
+	for _, scope := range scopes {
+		err = m.UnsetIncludes(ctx, m.ProfileIncludePattern(p.DirName), scope)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.KeepFiles {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(m.storageDir, p.DirName))
+}
+
+// Rename moves the profile with the given name to the directory of newName,
+// and returns its new directory. Includes of the profile aren't updated.
+func (m *Manager) Rename(ctx context.Context, name, newName string) (string, error) {
+	p, err := m.find(name)
+	if err != nil {
+		return "", err
+	}
+	if p.Name == DefaultName {
+		return "", ErrDefaultProfile
+	}
+	if newName == p.Name {
+		return "", ErrDuplicateProfile
+	}
+	if !strings.EqualFold(p.Name, newName) { // only changing the case is fine
+		err = m.ValidateName(newName)
+		if err != nil {
+			return "", err
+		}
+	}
+	newPath := m.Path(newName)
+	err = os.Rename(filepath.Join(m.storageDir, p.DirName), newPath)
+	if err != nil {
+		return "", err
+	}
+	return newPath, gitconfig.WriteFileAtomic(filepath.Join(newPath, "profile"), []byte(newName), 0o444)
+}
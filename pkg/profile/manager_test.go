@@ -0,0 +1,213 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	gitOutput, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s error = %v: %s", strings.Join(args, " "), err, gitOutput)
+	}
+	return strings.TrimSpace(string(gitOutput))
+}
+
+// newTestManager returns a Manager for a new repository, with a global config
+// of its own.
+func newTestManager(t *testing.T, gitExec bool) (*Manager, string) {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("filepath.EvalSymlinks() error = %v, want %v", err, nil)
+	}
+	t.Setenv("GIT_DIR", "")
+	os.Unsetenv("GIT_DIR")
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(root, "global.gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	repo := filepath.Join(root, "repo")
+	runGit(t, root, "init", "-q", "repo")
+
+	m, err := New(Options{StorageDir: filepath.Join(root, "git-sw"), Dir: repo, GitExec: gitExec})
+	if err != nil {
+		t.Fatalf("New() error = %v, want %v", err, nil)
+	}
+	return m, repo
+}
+
+func TestNew_RelativeStorageDir(t *testing.T) {
+	_, err := New(Options{StorageDir: "git-sw"})
+	if !errors.Is(err, ErrRelativeStorageDir) {
+		t.Errorf("New() error = %v, want %v", err, ErrRelativeStorageDir)
+	}
+}
+
+func TestManager(t *testing.T) {
+	for _, gitExec := range []bool{false, true} {
+		name := "Native"
+		if gitExec {
+			name = "Git Executable"
+		}
+		t.Run(name, func(t *testing.T) {
+			testManager(t, gitExec)
+		})
+	}
+}
+
+func testManager(t *testing.T, gitExec bool) {
+	ctx := context.Background()
+	m, repo := newTestManager(t, gitExec)
+
+	config := gitconfig.New()
+	err := config.Set("user.name", "Work")
+	if err != nil {
+		t.Fatalf("GitConfig.Set() error = %v, want %v", err, nil)
+	}
+	_, err = m.Create(ctx, CreateOptions{Name: "work", Config: config})
+	if err != nil {
+		t.Fatalf("Create() error = %v, want %v", err, nil)
+	}
+	_, err = m.Create(ctx, CreateOptions{Name: "Work"})
+	if !errors.Is(err, ErrDuplicateProfile) {
+		t.Errorf("Create() error = %v, want %v", err, ErrDuplicateProfile)
+	}
+	_, err = m.Create(ctx, CreateOptions{Name: " "})
+	if !errors.Is(err, ErrEmptyName) {
+		t.Errorf("Create() error = %v, want %v", err, ErrEmptyName)
+	}
+
+	scope, err := m.Use(ctx, "WORK", UseOptions{})
+	if err != nil || scope != Local {
+		t.Fatalf("Use() = (%v, %v), want (%v, %v)", scope, err, Local, nil)
+	}
+	if got := runGit(t, repo, "config", "user.name"); got != "Work" {
+		t.Errorf("git config user.name = %s, want %s", got, "Work")
+	}
+	current, err := m.Current(ctx, "")
+	if err != nil || current != "work" {
+		t.Errorf("Current() = (%v, %v), want (%v, %v)", current, err, "work", nil)
+	}
+	current, err = m.Current(ctx, Global)
+	if err != nil || current != DefaultName {
+		t.Errorf("Current(%s) = (%v, %v), want (%v, %v)", Global, current, err, DefaultName, nil)
+	}
+
+	profiles, err := m.List(ctx, ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v, want %v", err, nil)
+	}
+	want := []Profile{{Name: DefaultName, DirName: DirName(DefaultName)}, {Name: "work", DirName: DirName("work"), IsActive: true}}
+	if len(profiles) != len(want) || profiles[0] != want[0] || profiles[1] != want[1] {
+		t.Errorf("List() = %+v, want %+v", profiles, want)
+	}
+
+	err = m.Edit(ctx, "work", EditOptions{Edit: func(ctx context.Context, path string) error {
+		return os.WriteFile(path, []byte("[user]\n\tname = Edited\n"), 0o644)
+	}})
+	if err != nil {
+		t.Fatalf("Edit() error = %v, want %v", err, nil)
+	}
+	if got := runGit(t, repo, "config", "user.name"); got != "Edited" {
+		t.Errorf("git config user.name = %s, want %s", got, "Edited")
+	}
+	err = m.Edit(ctx, DefaultName, EditOptions{})
+	if !errors.Is(err, ErrDefaultProfile) {
+		t.Errorf("Edit(%s) error = %v, want %v", DefaultName, err, ErrDefaultProfile)
+	}
+
+	_, err = m.Use(ctx, DefaultName, UseOptions{})
+	if err != nil {
+		t.Fatalf("Use(%s) error = %v, want %v", DefaultName, err, nil)
+	}
+	if got, err := m.GetAll(ctx, "include.path", filepath.Join(repo, ".git", "config")); err != nil || len(got) != 0 {
+		t.Errorf("GetAll() = (%v, %v), want (%v, %v)", got, err, []string(nil), nil)
+	}
+
+	_, err = m.Use(ctx, "work", UseOptions{Scope: Global})
+	if err != nil {
+		t.Fatalf("Use(%s) error = %v, want %v", Global, err, nil)
+	}
+	err = m.Delete(ctx, "work", DeleteOptions{Scope: Global})
+	if err != nil {
+		t.Fatalf("Delete() error = %v, want %v", err, nil)
+	}
+	if _, err := os.Stat(m.Path("work")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("os.Stat() error = %v, want %v", err, os.ErrNotExist)
+	}
+	current, err = m.Current(ctx, Global)
+	if err != nil || current != DefaultName {
+		t.Errorf("Current(%s) = (%v, %v), want (%v, %v)", Global, current, err, DefaultName, nil)
+	}
+	_, err = m.Use(ctx, "work", UseOptions{})
+	if !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Use() error = %v, want %v", err, ErrProfileNotFound)
+	}
+}
+
+func TestManager_UseOutsideRepository(t *testing.T) {
+	m, repo := newTestManager(t, false)
+	m.dir = filepath.Dir(repo)
+
+	_, err := m.Use(context.Background(), DefaultName, UseOptions{})
+	if !errors.Is(err, ErrNotGitDirectory) {
+		t.Errorf("Use() error = %v, want %v", err, ErrNotGitDirectory)
+	}
+	scopes, err := m.WriteScopes(context.Background(), "")
+	if err != nil || len(scopes) != 1 || scopes[0] != Global {
+		t.Errorf("WriteScopes() = (%v, %v), want (%v, %v)", scopes, err, []Scope{Global}, nil)
+	}
+}
+
+func TestManager_RepositoryFallback(t *testing.T) {
+	m, repo := newTestManager(t, false)
+	configPath := filepath.Join(repo, ".git", "config")
+	runGit(t, repo, "config", "foo..x", "1") // git reads it, the native parser doesn't
+
+	r, ok, err := m.Repository(context.Background())
+	if err != nil || !ok || r.WorkTree != repo {
+		t.Errorf("Repository() = (%+v, %v, %v), want work tree %s", r, ok, err, repo)
+	}
+
+	err = os.WriteFile(configPath, []byte("[core]\n\tbare = false\n[foo]\n\tx = a\\q\n"), 0o644)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error = %v, want %v", err, nil)
+	}
+	_, ok, err = m.Repository(context.Background())
+	if err == nil || ok {
+		t.Errorf("Repository() = (%v, %v), want an error", ok, err)
+	}
+}
+
+func TestManager_Rename(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, false)
+	for _, name := range []string{"work", "home"} {
+		_, err := m.Create(ctx, CreateOptions{Name: name})
+		if err != nil {
+			t.Fatalf("Create(%s) error = %v, want %v", name, err, nil)
+		}
+	}
+
+	_, err := m.Rename(ctx, "work", "HOME")
+	if !errors.Is(err, ErrDuplicateProfile) {
+		t.Errorf("Rename() error = %v, want %v", err, ErrDuplicateProfile)
+	}
+	newPath, err := m.Rename(ctx, "work", "Work")
+	if err != nil || newPath != m.Path("Work") {
+		t.Fatalf("Rename() = (%v, %v), want (%v, %v)", newPath, err, m.Path("Work"), nil)
+	}
+	p, err := m.Get(ctx, "work")
+	if err != nil || p.Name != "Work" {
+		t.Errorf("Get() = (%+v, %v), want name %s", p, err, "Work")
+	}
+}
@@ -0,0 +1,277 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+// Scope is the git config file a profile is used in. The zero value means no
+// scope was requested, and each method picks the scope git itself would use.
+type Scope string
+
+const (
+	Local    Scope = "local"
+	Worktree Scope = "worktree"
+	Global   Scope = "global"
+	System   Scope = "system"
+)
+
+// Scopes are all the scopes.
+var Scopes = []Scope{Local, Worktree, Global, System}
+
+// Flag returns the git config option that selects the scope, e.g. --local.
+func (s Scope) Flag() string {
+	return "--" + string(s)
+}
+
+// IsRepoScope reports whether the scope needs to be used inside a repository.
+func (s Scope) IsRepoScope() bool {
+	return s == Local || s == Worktree
+}
+
+// Repository describes the repository the directory of a Manager belongs to.
+type Repository struct {
+	GitDir, CommonDir string
+	WorkTree          string // top-level directory of the work tree, if known
+	IsBare            bool
+	InsideGitDir      bool
+	InsideWorkTree    bool
+	WorktreeConfig    bool // extensions.worktreeConfig is enabled
+}
+
+// Repository returns the repository of the directory of m. ok is false if
+// the directory isn't part of any repository.
+func (m *Manager) Repository(ctx context.Context) (Repository, bool, error) {
+	if !m.gitExec {
+		repo, ok, err := m.nativeRepository()
+		if !useExecFallback(err) {
+			return repo, ok, err
+		}
+	}
+	return m.execRepository(ctx)
+}
+
+func (m *Manager) execRepository(ctx context.Context) (repo Repository, ok bool, err error) {
+	cmd := m.Command(ctx, "rev-parse", "--is-bare-repository", "--is-inside-git-dir", "--is-inside-work-tree", "--absolute-git-dir", "--git-common-dir")
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "LC_ALL=C") // to tell "not a git repository" apart
+	gitOutput, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// git exits with 128 on any fatal error, a broken config too
+			if exitErr.ExitCode() == 128 && bytes.Contains(exitErr.Stderr, []byte("not a git repository")) {
+				return Repository{}, false, nil
+			}
+			return Repository{}, false, fmt.Errorf("%w: %s", err, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return Repository{}, false, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(gitOutput)), "\n")
+	if len(lines) != 5 {
+		return Repository{}, false, fmt.Errorf("unexpected git rev-parse output: %q", gitOutput)
+	}
+	repo = Repository{
+		IsBare:         lines[0] == "true",
+		InsideGitDir:   lines[1] == "true",
+		InsideWorkTree: lines[2] == "true",
+		GitDir:         lines[3],
+		CommonDir:      lines[4],
+	}
+	if !filepath.IsAbs(repo.CommonDir) { // relative to the directory git ran in
+		repo.CommonDir = filepath.Join(m.dir, repo.CommonDir)
+	}
+
+	cmd = m.Command(ctx, "config", "--local", "--type=bool", "--get", "extensions.worktreeConfig")
+	gitOutput, err = cmd.Output()
+	if err != nil && cmd.ProcessState.ExitCode() != 1 {
+		return Repository{}, false, err
+	}
+	repo.WorktreeConfig = strings.TrimSpace(string(gitOutput)) == "true"
+
+	if repo.InsideWorkTree {
+		gitOutput, err = m.Command(ctx, "rev-parse", "--show-toplevel").Output()
+		if err != nil {
+			return Repository{}, false, err
+		}
+		repo.WorkTree = strings.TrimSpace(string(gitOutput))
+	}
+
+	return repo, true, nil
+}
+
+func (m *Manager) nativeRepository() (Repository, bool, error) {
+	dir := m.dir
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return Repository{}, false, err
+		}
+		dir = wd
+	}
+	repo, err := gitconfig.FindRepository(dir)
+	if err != nil {
+		if errors.Is(err, gitconfig.ErrNoRepository) {
+			return Repository{}, false, nil
+		}
+		return Repository{}, false, err
+	}
+	worktreeConfig, err := repo.WorktreeConfigEnabled()
+	if err != nil {
+		return Repository{}, false, err
+	}
+	return Repository{
+		GitDir:         repo.GitDir,
+		CommonDir:      repo.CommonDir,
+		IsBare:         repo.IsBare(),
+		InsideGitDir:   repo.InsideGitDir,
+		InsideWorkTree: !repo.InsideGitDir && !repo.IsBare(),
+		WorkTree:       repo.WorkTree,
+		WorktreeConfig: worktreeConfig,
+	}, true, nil
+}
+
+// InRepository reports whether the directory of m is inside a work tree or a
+// bare repository, i.e. a place where a repository scope makes sense. The
+// .git directory of a non-bare repository doesn't count.
+func (m *Manager) InRepository(ctx context.Context) (bool, error) {
+	repo, ok, err := m.Repository(ctx)
+	if err != nil {
+		return false, err
+	}
+	return ok && (repo.InsideWorkTree || repo.IsBare), nil
+}
+
+// checkScope returns ErrNotGitDirectory if scope needs a repository and the
+// directory of m isn't in one.
+func (m *Manager) checkScope(ctx context.Context, scope Scope) error {
+	if !scope.IsRepoScope() {
+		return nil
+	}
+	ok, err := m.InRepository(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotGitDirectory
+	}
+	return nil
+}
+
+// WriteScopes returns the scopes a profile include has to be written to or
+// removed from. Without an explicit scope, that's the repository config
+// (plus the worktree config, if enabled) inside a repository, or the
+// global config outside of one.
+func (m *Manager) WriteScopes(ctx context.Context, scope Scope) ([]Scope, error) {
+	if scope != "" {
+		err := m.checkScope(ctx, scope)
+		if err != nil {
+			return nil, err
+		}
+		return []Scope{scope}, nil
+	}
+	repo, ok, err := m.Repository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || !(repo.InsideWorkTree || repo.IsBare) {
+		return []Scope{Global}, nil
+	}
+	if repo.WorktreeConfig {
+		return []Scope{Local, Worktree}, nil
+	}
+	return []Scope{Local}, nil
+}
+
+// UseScope returns the scope Use writes to. Without an explicit scope, that's
+// the worktree config if extensions.worktreeConfig is enabled, the repository
+// config otherwise.
+func (m *Manager) UseScope(ctx context.Context, scope Scope) (Scope, error) {
+	if scope != "" {
+		err := m.checkScope(ctx, scope)
+		if err != nil {
+			return "", err
+		}
+		return scope, nil
+	}
+	repo, ok, err := m.Repository(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !ok || !(repo.InsideWorkTree || repo.IsBare) {
+		return "", ErrNotGitDirectory
+	}
+	if repo.WorktreeConfig {
+		return Worktree, nil
+	}
+	return Local, nil
+}
+
+// configFilePath returns the path of the config file of the given scope.
+func (m *Manager) configFilePath(ctx context.Context, scope Scope) (string, error) {
+	switch scope {
+	case Global:
+		return gitconfig.GlobalConfigPath()
+	case Local, Worktree:
+		repo, ok, err := m.Repository(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", ErrNotGitDirectory
+		}
+		if scope == Local || !repo.WorktreeConfig {
+			// like git, --worktree is the same as --local without extensions.worktreeConfig,
+			// as long as there's only one work tree
+			entries, err := os.ReadDir(filepath.Join(repo.CommonDir, "worktrees"))
+			if scope == Worktree && err == nil && len(entries) > 0 {
+				return "", ErrWorktreeConfigDisabled
+			}
+			return filepath.Join(repo.CommonDir, "config"), nil
+		}
+		return filepath.Join(repo.GitDir, "config.worktree"), nil
+	}
+	// the location of the system config depends on how git was built
+	return "", errNativeUnsupported
+}
+
+// ScopePath returns the path of the config file of the given scope. For the
+// system scope, that's its usual location, which is empty if the system
+// config is disabled.
+func (m *Manager) ScopePath(ctx context.Context, scope Scope) (string, error) {
+	path, err := m.configFilePath(ctx, scope)
+	if errors.Is(err, errNativeUnsupported) {
+		return gitconfig.SystemConfigPath(), nil
+	}
+	return path, err
+}
+
+// GitRunner makes the commands that run git, so they can be pointed at
+// another git executable or environment.
+type GitRunner interface {
+	Command(ctx context.Context, args ...string) *exec.Cmd
+}
+
+// ExecRunner runs the git executable found in PATH.
+type ExecRunner struct{}
+
+func (ExecRunner) Command(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "git", args...)
+}
+
+// Command returns a command that runs git with args in the directory of m.
+func (m *Manager) Command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := m.gitRunner.Command(ctx, args...)
+	cmd.Dir = m.dir
+	return cmd
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newProcessTestEnv returns a testEnv with a profile "work", created by
+// running git-sw as a process of its own, whose config files differ from the
+// ones of commands run in the test process.
+func newProcessTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	e := newTestEnv(t, func(out io.Writer) UserInterface {
+		return &NoTUI{Out: out}
+	})
+	if out, status := e.gitSw("--no-tui", "create", "work", "--name", "Work", "--email", "work@example.com"); status != 0 {
+		t.Fatalf("git-sw create exit status = %d, want %d (output %q)", status, 0, out)
+	}
+	return e
+}
+
+func TestProcess_ShellInitActivate(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil || runtime.GOOS == "windows" {
+		t.Skip("bash isn't available")
+	}
+	e := newProcessTestEnv(t)
+	script, status := e.gitSw("shell-init", "bash")
+	if status != 0 {
+		t.Fatalf("git-sw shell-init exit status = %d, want %d", status, 0)
+	}
+
+	cmd := exec.Command(bash, "-c", script+`
+git-sw --no-tui activate work 2>/dev/null || exit
+printf '%s\n' "$GIT_SW_PROFILE"
+git config user.email
+git-sw deactivate || exit
+printf '[%s]\n' "${GIT_SW_PROFILE-}"
+git config user.email || echo unset
+`)
+	cmd.Dir = e.repo
+	cmd.Env = gitSwCommand().Env
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("bash error = %v, want %v (output %q)", err, nil, out)
+	}
+	if want := "work\nwork@example.com\n[]\nunset\n"; string(out) != want {
+		t.Errorf("bash output = %q, want %q", out, want)
+	}
+}
+
+func TestProcess_ExecExitStatus(t *testing.T) {
+	e := newProcessTestEnv(t)
+
+	out, status := e.gitSw("--no-tui", "exec", "work", "--", "git", "config", "user.email")
+	if out != "work@example.com\n" || status != 0 {
+		t.Errorf("git-sw exec = (%q, %d), want (%q, %d)", out, status, "work@example.com\n", 0)
+	}
+	// git exits with 129 on an unknown option
+	_, status = e.gitSw("--no-tui", "exec", "work", "--", "git", "config", "--no-such-option")
+	if status != 129 {
+		t.Errorf("git-sw exec exit status = %d, want %d", status, 129)
+	}
+}
+
+func TestProcess_JSONErrors(t *testing.T) {
+	e := newProcessTestEnv(t)
+	tests := []struct {
+		args   []string
+		code   string
+		status int
+	}{
+		{[]string{"--output", "json", "--no-tui", "use", "nope"}, "PROFILE_NOT_FOUND", 57},
+		{[]string{"--output", "json", "nope"}, "INVALID_COMMAND", 13},
+		{[]string{"--output", "json", "--no-such-flag", "list"}, "INVALID_FLAG", 65},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			out, status := e.gitSw(tt.args...)
+			if status != tt.status {
+				t.Errorf("exit status = %d, want %d", status, tt.status)
+			}
+			var envelope errorEnvelope
+			err := json.Unmarshal([]byte(out), &envelope)
+			if err != nil {
+				t.Fatalf("json.Unmarshal(%q) error = %v, want %v", out, err, nil)
+			}
+			if envelope.Error.Code != tt.code || envelope.Error.Status != tt.status || envelope.Error.Message == "" {
+				t.Errorf("error = %+v, want code %s and status %d", envelope.Error, tt.code, tt.status)
+			}
+		})
+	}
+}
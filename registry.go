@@ -0,0 +1,177 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+const registryFileName = "repos.json"
+
+// RepoUse is a config file 'use' wrote the include of a profile to.
+type RepoUse struct {
+	Repo   string `json:"repo,omitempty"` // work tree (or git directory) of the repository, empty for global and system configs
+	Config string `json:"config"`
+	Scope  Scope  `json:"scope"`
+}
+
+// repoRegistry records where each profile is used, keyed by the profile's
+// directory name, so includes can be cleaned up outside the current repository.
+type repoRegistry map[string][]RepoUse
+
+func registryPath() string {
+	return filepath.Join(saveDirPath, registryFileName)
+}
+
+func loadRegistry() (repoRegistry, error) {
+	registry := make(repoRegistry)
+	content, err := os.ReadFile(registryPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return registry, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(content, &registry)
+	if err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+func (r repoRegistry) save() error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(saveDirPath, 0o744)
+	if err != nil {
+		return err
+	}
+	return gitconfig.WriteFileAtomic(registryPath(), append(content, '\n'), 0o644)
+}
+
+// removeConfig forgets every profile used in the config file at path.
+func (r repoRegistry) removeConfig(path string) {
+	for dirName, uses := range r {
+		uses = slices.DeleteFunc(uses, func(u RepoUse) bool { return u.Config == path })
+		if len(uses) == 0 {
+			delete(r, dirName)
+		} else {
+			r[dirName] = uses
+		}
+	}
+}
+
+// setConfig records that use is the only profile used in its config file.
+func (r repoRegistry) setConfig(dirName string, use RepoUse) {
+	r.removeConfig(use.Config)
+	r[dirName] = append(r[dirName], use)
+	slices.SortFunc(r[dirName], func(a, b RepoUse) int {
+		return cmp.Compare(a.Config, b.Config)
+	})
+}
+
+// newRepoUse describes the config file of scope.
+func newRepoUse(scope Scope) (RepoUse, error) {
+	path, err := scopeFilePath(scope)
+	if err != nil {
+		return RepoUse{}, err
+	}
+	use := RepoUse{Config: path, Scope: scope}
+	if scope.IsRepoScope() {
+		repo, _, err := getRepository()
+		if err != nil {
+			return RepoUse{}, err
+		}
+		use.Repo = cmp.Or(repo.WorkTree, repo.GitDir)
+	}
+	return use, nil
+}
+
+// profileIncludedIn reports whether the config file at path includes profile.
+func profileIncludedIn(profile Profile, path string) (bool, error) {
+	includes, err := getAllFile("include.path", path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	profileConfig := filepath.Join(saveDirPath, profile.DirName, ".gitconfig")
+	return slices.Contains(includes, profileConfig), nil
+}
+
+// registerUse records that profile is now used in the config file of scope,
+// or that no profile is if it's the default profile.
+func registerUse(entry *JournalEntry, profile Profile, scope Scope) error {
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	use, err := newRepoUse(scope)
+	if err != nil || use.Config == "" {
+		return err
+	}
+	err = entry.snapshot(registryPath())
+	if err != nil {
+		return err
+	}
+	if profile.Name == defaultConfigName {
+		registry.removeConfig(use.Config)
+	} else {
+		registry.setConfig(profile.DirName, use)
+	}
+	return registry.save()
+}
+
+// usedIn returns the config files that still include profile, according to
+// the registry.
+func (r repoRegistry) usedIn(profile Profile) ([]RepoUse, error) {
+	var uses []RepoUse
+	for _, use := range r[profile.DirName] {
+		included, err := profileIncludedIn(profile, use.Config)
+		if err != nil {
+			return nil, err
+		}
+		if included {
+			uses = append(uses, use)
+		}
+	}
+	return uses, nil
+}
+
+// unregisterProfile removes the includes of a deleted profile from every
+// config file it's registered in, and forgets about the profile.
+func unregisterProfile(entry *JournalEntry, profile Profile) ([]RepoUse, error) {
+	registry, err := loadRegistry()
+	if err != nil {
+		return nil, err
+	}
+	uses, err := registry.usedIn(profile)
+	if err != nil {
+		return nil, err
+	}
+	err = entry.snapshot(registryPath())
+	if err != nil {
+		return nil, err
+	}
+	for _, use := range uses {
+		err = entry.snapshotRepoUse(use)
+		if err != nil {
+			return nil, err
+		}
+		err = unsetConfigFile(manager.ProfileIncludePattern(profile.DirName), use.Config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	delete(registry, profile.DirName)
+	return uses, registry.save()
+}
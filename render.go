@@ -3,9 +3,11 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/mail"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"text/tabwriter"
 
@@ -15,7 +17,7 @@ import (
 )
 
 func validateNotEmpty(s string) error {
-	if len(s) <= 0 {
+	if strings.TrimSpace(s) == "" {
 		return ErrEmptyField
 	}
 	return nil
@@ -64,36 +66,97 @@ func displayCreateForm() (Profile, error) {
 	}
 
 	gitEmailPrompt := promptui.Prompt{
-		Label: "Git Email",
+		Label:    "Git Email",
+		Validate: validateEmail,
+	}
+
+	gitWithSigningKeyPrompt := promptui.Prompt{
+		Label:     "Add Signing Key",
+		IsConfirm: true,
+	}
+
+	gitGPGFormatSelect := promptui.Select{
+		Label:     "Select Key Format",
+		Items:     gpgFormat,
+		CursorPos: max(slices.Index(gpgFormat, GPGFormat(strings.ToLower(getSetting(keyFormatSettingKey)))), 0),
+		HideHelp:  true,
+	}
+
+	gitWithSSHKeyPrompt := promptui.Prompt{
+		Label:     "Add SSH Key",
+		IsConfirm: true,
+	}
+
+	gitSSHKeyPrompt := promptui.Prompt{
+		Label: "Enter path to your SSH private key",
 		Validate: func(s string) error {
 			err := validateNotEmpty(s)
 			if err != nil {
 				return err
 			}
-			_, err = mail.ParseAddress(s)
+			return validateSSHIdentity(s)
+		},
+	}
+
+	gitWithCredentialPrompt := promptui.Prompt{
+		Label:     "Add HTTPS Credential",
+		IsConfirm: true,
+	}
+
+	gitCredentialURLPrompt := promptui.Prompt{
+		Label:   "Credential URL",
+		Default: "https://github.com",
+		Validate: func(s string) error {
+			err := validateNotEmpty(s)
 			if err != nil {
-				return ErrInvalidEmail
+				return err
 			}
-			err = gitconfig.ValidateValue(s)
+			return validateCredentialURL(s)
+		},
+	}
+
+	gitCredentialUsernamePrompt := promptui.Prompt{
+		Label: "HTTPS Username",
+		Validate: func(s string) error {
+			err := validateNotEmpty(s)
 			if err != nil {
 				return err
 			}
-			return nil
+			return gitconfig.ValidateValue(s)
 		},
 	}
 
-	gitWithSigningKeyPrompt := promptui.Prompt{
-		Label:     "Add Signing Key",
-		IsConfirm: true,
+	gitCredentialHelperPrompt := promptui.Prompt{
+		Label:    "Credential helper (leave empty to keep the current one)",
+		Validate: gitconfig.ValidateValue,
 	}
 
-	gitGPGFormatSelect := promptui.Select{
-		Label:    "Select Key Format",
-		Items:    gpgFormat,
-		HideHelp: true,
+	gitRemotePrompt := promptui.Prompt{
+		Label: "Remotes this profile is for, e.g. github.com/acme (comma-separated, optional)",
+		Validate: func(s string) error {
+			_, err := parseRemotePatterns(s)
+			return err
+		},
+	}
+
+	gitDirectoryPrompt := promptui.Prompt{
+		Label: "Directories whose repositories this profile is for (comma-separated, optional)",
+		Validate: func(s string) error {
+			_, err := parseDirectoryBindings(s)
+			return err
+		},
+	}
+
+	gitCredentialUseHTTPPathPrompt := promptui.Prompt{
+		Label:     "Scope credentials per repository path (credential.useHttpPath)",
+		IsConfirm: true,
 	}
 
-	profile.Name, err = profileNamePrompt.Run()
+	if profileFlag != "" { // given on the command line
+		profile.Name, err = profileFlag, profileNamePrompt.Validate(profileFlag)
+	} else {
+		profile.Name, err = profileNamePrompt.Run()
+	}
 	if err != nil {
 		return Profile{}, err
 	}
@@ -127,7 +190,7 @@ func displayCreateForm() (Profile, error) {
 		if keyFormat == OPENPGP {
 			gpgProgramPrompt := new(promptui.Prompt)
 			gpgProgramPrompt.Label = "Enter your GPG program"
-			gpgProgramPrompt.Default = "gpg"
+			gpgProgramPrompt.Default = getSetting(gpgProgramSettingKey)
 			gpgProgram, err := gpgProgramPrompt.Run()
 			if err != nil {
 				return Profile{}, err
@@ -152,10 +215,99 @@ func displayCreateForm() (Profile, error) {
 	} else if !errors.Is(err, promptui.ErrAbort) {
 		return Profile{}, err
 	}
+	_, err = gitWithSSHKeyPrompt.Run()
+	if err == nil {
+		sshKey, err := gitSSHKeyPrompt.Run()
+		if err != nil {
+			return Profile{}, err
+		}
+		identityPath, err := sshIdentityPath(sshKey)
+		if err != nil {
+			return Profile{}, err
+		}
+		err = profile.Config.Set("core.sshCommand", sshCommand(identityPath))
+		if err != nil {
+			return Profile{}, err
+		}
+	} else if !errors.Is(err, promptui.ErrAbort) {
+		return Profile{}, err
+	}
+	remotes, err := gitRemotePrompt.Run()
+	if err != nil {
+		return Profile{}, err
+	}
+	directories, err := gitDirectoryPrompt.Run()
+	if err != nil {
+		return Profile{}, err
+	}
+	err = addBindings(profile.Config, remotes, directories)
+	if err != nil {
+		return Profile{}, err
+	}
+	_, err = gitWithCredentialPrompt.Run()
+	if err == nil {
+		credentialURL, err := gitCredentialURLPrompt.Run()
+		if err != nil {
+			return Profile{}, err
+		}
+		credentialUsername, err := gitCredentialUsernamePrompt.Run()
+		if err != nil {
+			return Profile{}, err
+		}
+		err = profile.Config.Set(fmt.Sprintf("credential.%s.username", credentialURL), credentialUsername)
+		if err != nil {
+			return Profile{}, err
+		}
+		credentialHelper, err := gitCredentialHelperPrompt.Run()
+		if err != nil {
+			return Profile{}, err
+		}
+		if credentialHelper != "" {
+			err = profile.Config.Set("credential.helper", credentialHelper)
+			if err != nil {
+				return Profile{}, err
+			}
+		}
+		_, err = gitCredentialUseHTTPPathPrompt.Run()
+		if err == nil {
+			err = profile.Config.Set("credential.useHttpPath", true)
+			if err != nil {
+				return Profile{}, err
+			}
+		} else if !errors.Is(err, promptui.ErrAbort) {
+			return Profile{}, err
+		}
+	} else if !errors.Is(err, promptui.ErrAbort) {
+		return Profile{}, err
+	}
 
 	return profile, nil
 }
 
+func validateEmail(s string) error {
+	err := validateNotEmpty(s)
+	if err != nil {
+		return err
+	}
+	_, err = mail.ParseAddress(s)
+	if err != nil {
+		return ErrInvalidEmail
+	}
+	err = gitconfig.ValidateValue(s)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func displayEmailPrompt(profile Profile) (string, error) {
+	prompt := promptui.Prompt{
+		Label:    fmt.Sprintf("Git Email for \"%s\"", profile.Name),
+		Validate: validateEmail,
+	}
+	return prompt.Run()
+}
+
 func displayProfileSelector(profiles []Profile) (Profile, error) {
 	keys := &promptui.SelectKeys{
 		Prev:     promptui.Key{Code: promptui.KeyPrev, Display: promptui.KeyPrevDisplay},
@@ -192,8 +344,8 @@ func displayProfileSelector(profiles []Profile) (Profile, error) {
 	return profiles[ix], nil
 }
 
-func displayProfileList(profiles []Profile) error {
-	tw := tabwriter.NewWriter(os.Stdout, 4, 4, 0, ' ', 0)
+func displayProfileList(w io.Writer, profiles []Profile) error {
+	tw := tabwriter.NewWriter(w, 4, 4, 0, ' ', 0)
 	_, err := fmt.Fprint(tw, "List of available profiles:\n")
 	if err != nil {
 		return err
@@ -201,10 +353,14 @@ func displayProfileList(profiles []Profile) error {
 	for i, profile := range profiles {
 		fmt.Fprintf(tw, "%d.\tName: %s ", i+1, profile.Name)
 		if profile.IsActive {
-			fmt.Fprint(tw, promptui.Styler(promptui.FGGreen)("(active)"))
+			fmt.Fprint(tw, styler(promptui.Styler(promptui.FGGreen))("(active)"))
 		}
 		fmt.Fprint(tw, "\n")
-		fmt.Fprintf(tw, "\tPath: %s\n", filepath.Join(saveDirPath, profile.DirName))
+		path, err := manager.ConfigPath(profile)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "\tPath: %s\n", path)
 	}
 	err = tw.Flush()
 	if err != nil {
@@ -213,7 +369,112 @@ func displayProfileList(profiles []Profile) error {
 	return nil
 }
 
+func displayBackupSelector(backups []Backup) (Backup, error) {
+	prompt := promptui.Select{
+		Label: "Backup",
+		Items: backups,
+		Size:  5,
+		Templates: &promptui.SelectTemplates{
+			Label:    "Please select a backup to restore",
+			Active:   "> {{ .Name | cyan }}\t{{ .Kind | blue }}\t{{ .Time.Local.Format \"2006-01-02 15:04:05\" }}",
+			Inactive: "  {{ .Name }}\t{{ .Kind | blue }}\t{{ .Time.Local.Format \"2006-01-02 15:04:05\" }}",
+			Selected: "> {{ .Name | cyan }}",
+		},
+		HideHelp: true,
+	}
+
+	ix, _, err := prompt.Run()
+	if err != nil {
+		return Backup{}, err
+	}
+	return backups[ix], nil
+}
+
+func displayRenameForm(profile Profile, profiles []Profile) (string, error) {
+	if newNameFlag != "" { // given on the command line
+		return newNameFlag, validateNewProfileName(newNameFlag, profile, profiles)
+	}
+	prompt := promptui.Prompt{
+		Label:   "New Name",
+		Default: profile.Name,
+		Validate: func(s string) error {
+			return validateNewProfileName(s, profile, profiles)
+		},
+	}
+	return prompt.Run()
+}
+
+func displayHistory(w io.Writer, entries []JournalEntry) error {
+	undone := undoneEntries(entries)
+	tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+	_, err := fmt.Fprint(tw, "History (newest first):\n")
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		fmt.Fprintf(tw, "%d.\t%s\t%s\t%s", entry.ID, entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Command, entry.Profile)
+		if entry.Undoes != 0 {
+			fmt.Fprintf(tw, " (reverts %d)", entry.Undoes)
+		}
+		if undone[entry.ID] {
+			fmt.Fprint(tw, " "+styler(promptui.Styler(promptui.FGYellow))("(undone)"))
+		}
+		fmt.Fprintf(tw, "\t%s\n", strings.Join(entry.Repos, ", "))
+	}
+	return tw.Flush()
+}
+
+func displayRepoList(w io.Writer, profile Profile, uses []RepoUse) error {
+	if len(uses) == 0 {
+		fmt.Fprintf(w, "Profile \"%s\" isn't used anywhere.\n", profile.Name)
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Profile \"%s\" is used in:\n", profile.Name)
+	for i, use := range uses {
+		location := use.Repo
+		if location == "" {
+			location = fmt.Sprintf("%s config", use.Scope)
+		}
+		fmt.Fprintf(tw, "%d.\t%s\t%s\n", i+1, location, styler(promptui.Styler(promptui.FGBlue))(use.Config))
+	}
+	return tw.Flush()
+}
+
+func displayScanResults(w io.Writer, results []ScanResult) error {
+	tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Found %d repositories:\n", len(results))
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(tw, "%s\t%s\n", r.Path, styler(promptui.Styler(promptui.FGRed))(r.Err))
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s", r.Path, styler(promptui.Styler(promptui.FGCyan))(r.Profile), r.Email, strings.Join(r.Remotes, ", "))
+		if r.Mismatch != "" {
+			fmt.Fprint(tw, "\t"+styler(promptui.Styler(promptui.FGYellow))(r.Mismatch))
+		}
+		fmt.Fprint(tw, "\n")
+	}
+	return tw.Flush()
+}
+
+func displayConfirmation(label string) bool {
+	if !getBoolSetting(confirmSettingKey) {
+		return true
+	}
+	prompt := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}
+	_, err := prompt.Run()
+	return err == nil
+}
+
 func displayDeleteConfirmation() bool {
+	if !getBoolSetting(confirmSettingKey) {
+		return true
+	}
 	deletePrompt := promptui.Prompt{
 		Label:     "You're about to delete a GLOBAL config file, do you want to proceed",
 		IsConfirm: true,
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ScanResult is the identity a repository found by scan commits with.
+type ScanResult struct {
+	Path     string
+	Profile  string // active git-sw profile
+	Email    string // effective user.email
+	Remotes  []string
+	Mismatch string // why the remotes don't fit the profile, empty if they do
+	Err      error
+}
+
+// repoScanner walks a directory tree, handing subtrees to new goroutines
+// while there are free slots in sem and walking them itself otherwise, so
+// there are never more than cap(sem) extra goroutines.
+type repoScanner struct {
+	bindings []profileBinding
+	sem      chan struct{}
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	results []ScanResult
+}
+
+// scanRepos finds every git repository under root and reports its identity.
+func scanRepos(root string, profiles []Profile) ([]ScanResult, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrNotDirectory, root)
+	}
+	bindings, err := loadBindings(profiles)
+	if err != nil {
+		return nil, err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &repoScanner{
+		bindings: bindings,
+		sem:      make(chan struct{}, max(4, runtime.NumCPU())),
+	}
+	s.walk(root)
+	s.wg.Wait()
+
+	slices.SortFunc(s.results, func(a, b ScanResult) int {
+		return cmp.Compare(a.Path, b.Path)
+	})
+	return s.results, nil
+}
+
+func (s *repoScanner) walk(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrPermission) {
+			s.add(ScanResult{Path: dir, Err: err})
+		}
+		return
+	}
+	if slices.ContainsFunc(entries, func(e fs.DirEntry) bool { return e.Name() == ".git" }) {
+		s.add(s.inspect(dir))
+	}
+	for _, entry := range entries {
+		// nested repositories such as submodules are reported too
+		if !entry.IsDir() || entry.Name() == ".git" {
+			continue
+		}
+		subdir := filepath.Join(dir, entry.Name())
+		select {
+		case s.sem <- struct{}{}:
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer func() { <-s.sem }()
+				s.walk(subdir)
+			}()
+		default:
+			s.walk(subdir)
+		}
+	}
+}
+
+func (s *repoScanner) add(result ScanResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, result)
+}
+
+// inspect reads the effective config of the repository in dir, with includes
+// resolved by git.
+func (s *repoScanner) inspect(dir string) ScanResult {
+	result := ScanResult{Path: dir, Profile: defaultConfigName}
+	cmd := manager.Command(context.Background(), "-C", dir, "config", "--get-regexp", `^(user\.email|remote\..*\.url|include\.path)$`)
+	gitOutput, err := cmd.Output()
+	if err != nil && (cmd.ProcessState == nil || cmd.ProcessState.ExitCode() != 1) { // 1: none of the keys is set
+		result.Err = err
+		return result
+	}
+
+	for _, line := range strings.Split(string(gitOutput), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch {
+		case key == "user.email":
+			result.Email = value
+		case key == "include.path" && manager.IncludePattern().MatchString(value):
+			result.Profile = profileNameFromInclude(value)
+		case strings.HasPrefix(key, "remote."):
+			result.Remotes = append(result.Remotes, value)
+		}
+	}
+	result.Mismatch = identityMismatch(s.bindings, result.Profile, result.Remotes, dir)
+	return result
+}
+
+// profileNameFromInclude returns the name of the profile a git-sw include
+// points at.
+func profileNameFromInclude(include string) string {
+	profileName, err := os.ReadFile(filepath.Join(filepath.Dir(include), "profile"))
+	if err != nil {
+		return filepath.Base(filepath.Dir(include)) // deleted profile, see doctor
+	}
+	return string(profileName)
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+)
+
+// settingsFileName is the settings file of git-sw, in the git-sw directory
+// of the user config directory. It stays there when core.storageDir moves
+// the profiles elsewhere.
+const settingsFileName = "config"
+
+const (
+	editorSettingKey     = "core.editor"
+	storageDirSettingKey = "core.storageDir"
+	confirmSettingKey    = "core.confirm"
+	tuiSettingKey        = "core.tui"
+	keyFormatSettingKey  = "create.keyFormat"
+	gpgProgramSettingKey = "create.gpgProgram"
+	autoBindSettingKey   = "use.autoBind"
+	outputSettingKey     = "core.output"
+)
+
+// The output formats of errors.
+const (
+	textOutput = "text"
+	jsonOutput = "json"
+)
+
+// setting is a default of git-sw. It's read from the environment, then the
+// git config if it has a git key there, then the settings file.
+type setting struct {
+	Key         string // in the settings file
+	Env         string
+	GitKey      string // in the git config, so it can be set per repository
+	Default     string
+	Validate    func(string) error
+	Description string
+}
+
+var settings = []setting{
+	{
+		Key:         editorSettingKey,
+		Env:         "GIT_SW_EDITOR",
+		Default:     defaultEditor(),
+		Validate:    validateNotEmpty,
+		Description: "Editor command edit opens profiles with, e.g. 'code --wait'.",
+	},
+	{
+		Key:         storageDirSettingKey,
+		Env:         "GIT_SW_STORAGE_DIR",
+		Validate:    validateStorageDir,
+		Description: "Directory profiles, backups and the history are stored in. Profiles aren't moved when it changes.",
+	},
+	{
+		Key:         confirmSettingKey,
+		Env:         "GIT_SW_CONFIRM",
+		Default:     "true",
+		Validate:    validateBool,
+		Description: "Ask before destructive operations. When false, they proceed as if --yes was given.",
+	},
+	{
+		Key:         tuiSettingKey,
+		Env:         "GIT_SW_TUI",
+		GitKey:      "sw.tui",
+		Default:     "true",
+		Validate:    validateBool,
+		Description: "Show the TUI. When false, git-sw runs as if --no-tui was given.",
+	},
+	{
+		Key:         outputSettingKey,
+		Env:         "GIT_SW_OUTPUT",
+		GitKey:      "sw.output",
+		Default:     textOutput,
+		Validate:    validateOutput,
+		Description: "Output format of errors: 'text', or 'json' for an object with the code, message and exit status of the error.",
+	},
+	{
+		Key:         keyFormatSettingKey,
+		Env:         "GIT_SW_KEY_FORMAT",
+		Default:     string(OPENPGP),
+		Validate:    validateKeyFormat,
+		Description: "Signing key format create uses when --key-format isn't given: 'openpgp', 'ssh', or 'x509'.",
+	},
+	{
+		Key:         gpgProgramSettingKey,
+		Env:         "GIT_SW_GPG_PROGRAM",
+		Default:     "gpg",
+		Validate:    validateNotEmpty,
+		Description: "GPG program create uses for openpgp keys when --gpg-program isn't given.",
+	},
+	{
+		Key:         autoBindSettingKey,
+		Env:         "GIT_SW_AUTO_BIND",
+		GitKey:      "sw.autoBind",
+		Default:     "false",
+		Validate:    validateBool,
+		Description: "Make use bind the selected profile to the remotes of the repository, unless a profile is already bound to them.",
+	},
+}
+
+// settingValues holds the value of every setting, see loadSettings.
+var settingValues = map[string]string{}
+
+func defaultEditor() string {
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vim"
+}
+
+// defaultValue returns the default of s for the user config directory
+// configDir, which the default storage directory is in.
+func (s setting) defaultValue(configDir string) string {
+	if s.Key == storageDirSettingKey {
+		return filepath.Join(configDir, saveDirName)
+	}
+	return s.Default
+}
+
+// settingsPath returns the path of the settings file in the user config
+// directory configDir.
+func settingsPath(configDir string) string {
+	return filepath.Join(configDir, saveDirName, settingsFileName)
+}
+
+// findSetting returns the setting with the given key, ignoring case like git.
+func findSetting(key string) (setting, error) {
+	i := slices.IndexFunc(settings, func(s setting) bool { return strings.EqualFold(s.Key, key) })
+	if i < 0 {
+		return setting{}, fmt.Errorf("%w: %s", ErrUnknownSetting, key)
+	}
+	return settings[i], nil
+}
+
+func validateBool(s string) error {
+	_, err := gitconfig.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidBool, s)
+	}
+	return nil
+}
+
+// validateStorageDir checks that dir is absolute, or relative to the home
+// directory, so it doesn't depend on where git-sw is run.
+func validateStorageDir(dir string) error {
+	if !filepath.IsAbs(expandHome(dir)) {
+		return ErrRelativeStorageDir
+	}
+	return nil
+}
+
+func validateOutput(s string) error {
+	if s != textOutput && s != jsonOutput {
+		return ErrInvalidOutput
+	}
+	return nil
+}
+
+// outputFormat returns the output format of errors. Until the settings are
+// loaded, only --output is known.
+func outputFormat() string {
+	return cmp.Or(outputFlag, getSetting(outputSettingKey), textOutput)
+}
+
+func validateKeyFormat(s string) error {
+	if !slices.Contains(gpgFormat, GPGFormat(strings.ToLower(s))) {
+		return ErrInvalidKeyFormat
+	}
+	return nil
+}
+
+// loadSettingsFile reads the settings file, which is empty if it doesn't
+// exist yet.
+func loadSettingsFile(path string) (*gitconfig.GitConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return gitconfig.New(), nil
+		}
+		return nil, err
+	}
+	file, err := gitconfig.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// maxIncludeDepth is how deep include.path is followed, the same limit git has.
+const maxIncludeDepth = 10
+
+// gitSettings returns the settings set in the git config, by their lowercase
+// git key. The config files are read directly, following include.path but not
+// includeIf. git is only run with --git-exec or if a file can't be parsed, and
+// if it isn't installed there are no settings.
+func gitSettings(app *AppState) (map[string]string, error) {
+	if gitExecFlag {
+		return execGitSettings(app.Git)
+	}
+	var parseErr *gitconfig.ParseError
+	paths, err := gitConfigPaths()
+	if errors.As(err, &parseErr) {
+		return execGitSettings(app.Git)
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, path := range paths {
+		err = readGitSettings(path, app.HomeDir, values, 0)
+		if errors.As(err, &parseErr) {
+			return execGitSettings(app.Git)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// gitConfigPaths returns the config files git reads in the current
+// directory, in the order it reads them.
+func gitConfigPaths() ([]string, error) {
+	var paths []string
+	if systemPath := gitconfig.SystemConfigPath(); systemPath != "" {
+		paths = append(paths, systemPath)
+	}
+	globalPaths, err := gitconfig.GlobalConfigPaths()
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, globalPaths...)
+	repo, err := gitconfig.FindRepository(".")
+	if errors.Is(err, gitconfig.ErrNoRepository) {
+		return paths, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, repo.ConfigPath())
+	worktreeConfig, err := repo.WorktreeConfigEnabled()
+	if err != nil {
+		return nil, err
+	}
+	if worktreeConfig {
+		paths = append(paths, repo.WorktreeConfigPath())
+	}
+	return paths, nil
+}
+
+// readGitSettings adds the settings set in the config file at path, and in
+// the files it includes, to values. Files that don't exist or can't be read
+// are skipped, like git does.
+func readGitSettings(path, homeDir string, values map[string]string, depth int) error {
+	f, err := gitconfig.OpenFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return nil
+		}
+		return err
+	}
+	for _, s := range settings {
+		if s.GitKey == "" {
+			continue
+		}
+		v, err := f.GetAll(s.GitKey)
+		if err != nil && !errors.Is(err, gitconfig.ErrKeyNotFound) {
+			return err
+		}
+		if len(v) > 0 {
+			values[strings.ToLower(s.GitKey)] = settingString(v[len(v)-1])
+		}
+	}
+	if depth >= maxIncludeDepth {
+		return nil
+	}
+	includes, err := f.GetAll("include.path")
+	if err != nil && !errors.Is(err, gitconfig.ErrKeyNotFound) {
+		return err
+	}
+	for _, include := range includes {
+		includePath := include.String()
+		if includePath == "~" || strings.HasPrefix(includePath, "~/") {
+			includePath = filepath.Join(homeDir, includePath[1:])
+		} else if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(path), includePath)
+		}
+		err = readGitSettings(includePath, homeDir, values, depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execGitSettings is gitSettings through the git executable.
+func execGitSettings(git GitRunner) (map[string]string, error) {
+	var keys []string
+	for _, s := range settings {
+		if s.GitKey != "" {
+			keys = append(keys, regexp.QuoteMeta(strings.ToLower(s.GitKey)))
+		}
+	}
+	cmd := git.Command(context.Background(), "config", "--get-regexp", fmt.Sprintf("^(%s)$", strings.Join(keys, "|")))
+	gitOutput, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, nil
+		}
+		if cmd.ProcessState != nil && cmd.ProcessState.ExitCode() == 1 { // none set
+			return nil, nil
+		}
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(gitOutput)), "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok { // a key without a value is true
+			value = "true"
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// settingString returns a value of a setting as a string. A key without a
+// value is true, like git says.
+func settingString(value gitconfig.Value) string {
+	if value.Implicit() {
+		return "true"
+	}
+	return value.String()
+}
+
+// settingSource returns the value of s and where it comes from, or an empty
+// source for the default. file is the settings file at path.
+func settingSource(s setting, file *gitconfig.GitConfig, path, configDir string, gitValues map[string]string) (string, string) {
+	if value := os.Getenv(s.Env); value != "" {
+		return value, s.Env
+	}
+	if value, ok := gitValues[strings.ToLower(s.GitKey)]; ok && s.GitKey != "" {
+		return value, s.GitKey
+	}
+	if value, err := file.Get(s.Key); err == nil {
+		return settingString(value), path
+	}
+	return s.defaultValue(configDir), ""
+}
+
+// loadSettings reads every setting of app into settingValues, checking the
+// ones that aren't defaults.
+func loadSettings(app *AppState) error {
+	path := settingsPath(app.ConfigDir)
+	file, err := loadSettingsFile(path)
+	if err != nil {
+		return err
+	}
+	gitValues, err := gitSettings(app)
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		value, source := settingSource(s, file, path, app.ConfigDir, gitValues)
+		if source != "" && s.Validate != nil {
+			err = s.Validate(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s in %s: %w", s.Key, source, err)
+			}
+		}
+		settingValues[s.Key] = value
+	}
+	return nil
+}
+
+// getSetting returns the value of the setting with the given key.
+func getSetting(key string) string {
+	return settingValues[key]
+}
+
+// getBoolSetting returns the value of a boolean setting, which
+// loadSettings has already checked.
+func getBoolSetting(key string) bool {
+	b, _ := gitconfig.ParseBool(getSetting(key))
+	return b
+}
+
+// storageDir returns the directory profiles are stored in.
+func storageDir() (string, error) {
+	dir := expandHome(getSetting(storageDirSettingKey))
+	if dir == "" {
+		return "", ErrEmptyField
+	}
+	return filepath.Clean(dir), nil
+}
+
+// setSetting writes the setting with the given key to the settings file in
+// the user config directory configDir.
+func setSetting(configDir, key, value string) (setting, error) {
+	s, err := findSetting(key)
+	if err != nil {
+		return setting{}, err
+	}
+	if s.Validate != nil {
+		err = s.Validate(value)
+		if err != nil {
+			return setting{}, err
+		}
+	}
+	err = gitconfig.ValidateValue(value)
+	if err != nil {
+		return setting{}, err
+	}
+	path := settingsPath(configDir)
+	file, err := loadSettingsFile(path)
+	if err != nil {
+		return setting{}, err
+	}
+	err = file.Set(s.Key, value)
+	if err != nil {
+		return setting{}, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o744)
+	if err != nil {
+		return setting{}, err
+	}
+	return s, file.Save(path)
+}
+
+// unsetSetting removes the setting with the given key from the settings file
+// in the user config directory configDir.
+func unsetSetting(configDir, key string) (setting, error) {
+	s, err := findSetting(key)
+	if err != nil {
+		return setting{}, err
+	}
+	path := settingsPath(configDir)
+	file, err := loadSettingsFile(path)
+	if err != nil {
+		return setting{}, err
+	}
+	if _, err := file.Get(s.Key); err != nil {
+		return s, nil // not set
+	}
+	err = file.Unset(s.Key)
+	if err != nil {
+		return setting{}, err
+	}
+	return s, file.Save(path)
+}
+
+// applyGitOptions handles -C and --git-dir the way git does: every -C is
+// relative to the previous one, and --git-dir to the resulting directory.
+// The git directory is passed on as GIT_DIR, which git and the lookup of the
+// local config file both honour.
+func applyGitOptions() error {
+	for _, dir := range chdirFlags {
+		if dir == "" { // ignored by git too
+			continue
+		}
+		err := os.Chdir(dir)
+		if err != nil {
+			return fmt.Errorf("cannot change to '%s': %w", dir, errors.Unwrap(err))
+		}
+	}
+	if gitDirFlag == "" {
+		return nil
+	}
+	gitDir, err := filepath.Abs(gitDirFlag)
+	if err != nil {
+		return err
+	}
+	return os.Setenv("GIT_DIR", gitDir)
+}
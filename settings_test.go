@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// missingGit is a GitRunner for a git that isn't installed.
+type missingGit struct{}
+
+func (missingGit) Command(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "git-sw-test-missing-git", args...)
+}
+
+func TestGitSettings(t *testing.T) {
+	e := newTestEnv(t, func(out io.Writer) UserInterface {
+		return &NoTUI{Out: out}
+	})
+	err := os.WriteFile(filepath.Join(e.root, "included"), []byte("[sw]\n\tautoBind = true\n"), 0o644)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error = %v, want %v", err, nil)
+	}
+	e.git(e.repo, "config", "--global", "sw.output", "json")
+	e.git(e.repo, "config", "--global", "sw.tui", "false")
+	e.git(e.repo, "config", "sw.tui", "true")
+	e.git(e.repo, "config", "include.path", "../../included")
+	want := map[string]string{"sw.output": "json", "sw.tui": "true", "sw.autobind": "true"}
+
+	got, err := gitSettings(e.app)
+	if err != nil {
+		t.Fatalf("gitSettings() error = %v, want %v", err, nil)
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("gitSettings() = %v, want %v", got, want)
+	}
+
+	gitExecFlag = true
+	t.Cleanup(resetFlags)
+	got, err = gitSettings(e.app)
+	if err != nil {
+		t.Fatalf("gitSettings() with --git-exec error = %v, want %v", err, nil)
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("gitSettings() with --git-exec = %v, want %v", got, want)
+	}
+	e.app.Git = missingGit{}
+	got, err = gitSettings(e.app)
+	if err != nil || len(got) != 0 {
+		t.Errorf("gitSettings() without git = %v, %v, want no settings", got, err)
+	}
+}
+
+func TestLoadSettings_Bool(t *testing.T) {
+	e := newTestEnv(t, func(out io.Writer) UserInterface {
+		return &NoTUI{Out: out}
+	})
+	// an empty value is false, a key without any value true
+	err := os.WriteFile(filepath.Join(e.app.HomeDir, ".gitconfig"), []byte("[sw]\n\ttui =\n\tautoBind\n"), 0o644)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error = %v, want %v", err, nil)
+	}
+	err = loadSettings(e.app)
+	if err != nil {
+		t.Fatalf("loadSettings() error = %v, want %v", err, nil)
+	}
+	if getBoolSetting(tuiSettingKey) {
+		t.Errorf("getBoolSetting(%s) = %v, want %v", tuiSettingKey, true, false)
+	}
+	if !getBoolSetting(autoBindSettingKey) {
+		t.Errorf("getBoolSetting(%s) = %v, want %v", autoBindSettingKey, false, true)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// activeProfileEnv holds the name of the profile activated in the shell
+	// or applied by exec.
+	activeProfileEnv = "GIT_SW_PROFILE"
+	// activeBaseEnv holds the GIT_CONFIG_COUNT from before activate, so
+	// deactivate only removes the variables git-sw added.
+	activeBaseEnv = "GIT_SW_CONFIG_BASE"
+	// shellEnv is set by the shell integration to the shell the output of
+	// activate and deactivate is evaluated by.
+	shellEnv = "GIT_SW_SHELL"
+)
+
+var initShells = []string{"bash", "zsh", "fish"}
+
+// posixInit and fishInit wrap git-sw in a function that evaluates the output
+// of activate and deactivate, as those change the environment of the shell.
+// Only the subcommand counts, the first argument that is neither a flag nor
+// the value of one; every other command runs unchanged.
+const (
+	posixInit = `git-sw() {
+	local arg skip= sub=
+	for arg in "$@"; do
+		case "$skip" in
+		value) skip=; continue ;;
+		command) sub="$arg"; break ;;
+		esac
+		case "$arg" in
+		--) skip=command ;;
+		%[4]s) skip=value ;;
+		-*) ;;
+		*) sub="$arg"; break ;;
+		esac
+	done
+	case "$sub" in
+	activate|deactivate)
+		local script
+		if ! script="$(%[2]s=%[1]s command %[3]s "$@")"; then
+			printf '%%s\n' "$script" >&2
+			return 1
+		fi
+		eval "$script"
+		return
+		;;
+	esac
+	command %[3]s "$@"
+}
+`
+	fishInit = `function git-sw
+	set -l skip
+	set -l sub
+	for arg in $argv
+		switch "$skip"
+			case value
+				set skip
+				continue
+			case command
+				set sub $arg
+				break
+		end
+		switch $arg
+			case '--'
+				set skip command
+			case %[4]s
+				set skip value
+			case '-*'
+			case '*'
+				set sub $arg
+				break
+		end
+	end
+	switch "$sub"
+		case activate deactivate
+			set -l script (%[2]s=%[1]s command %[3]s $argv)
+			or begin
+				printf '%%s\n' $script >&2
+				return 1
+			end
+			string join \n -- $script | source
+			return
+	end
+	command %[3]s $argv
+end
+`
+)
+
+// valueFlags returns the flags of git-sw that take a value, as both -name
+// and --name, which the shell integration has to skip along with the value.
+func valueFlags() []string {
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			return
+		}
+		names = append(names, "-"+f.Name, "--"+f.Name)
+	})
+	return names
+}
+
+// shellInit returns the shell integration for shell.
+func shellInit(shell string) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return initScript(shell, executable)
+}
+
+// initScript returns the shell integration for shell that runs executable.
+func initScript(shell, executable string) (string, error) {
+	switch shell {
+	case "bash", "zsh":
+		return fmt.Sprintf(posixInit, shell, shellEnv, shellQuote(executable), strings.Join(valueFlags(), "|")), nil
+	case "fish":
+		quoted := valueFlags()
+		for i := range quoted {
+			quoted[i] = fishQuote(quoted[i])
+		}
+		return fmt.Sprintf(fishInit, shell, shellEnv, fishQuote(executable), strings.Join(quoted, " ")), nil
+	}
+	return "", ErrInvalidShell
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// shellScript builds the statements activate and deactivate print for the
+// shell set by the shell integration.
+type shellScript struct {
+	fish bool
+	sb   strings.Builder
+}
+
+func newShellScript() (*shellScript, error) {
+	switch os.Getenv(shellEnv) {
+	case "bash", "zsh":
+		return &shellScript{}, nil
+	case "fish":
+		return &shellScript{fish: true}, nil
+	}
+	return nil, ErrShellNotInitialized
+}
+
+func (s *shellScript) set(name, value string) {
+	if s.fish {
+		fmt.Fprintf(&s.sb, "set -gx %s %s\n", name, fishQuote(value))
+	} else {
+		fmt.Fprintf(&s.sb, "export %s=%s\n", name, shellQuote(value))
+	}
+}
+
+func (s *shellScript) unset(name string) {
+	if s.fish {
+		fmt.Fprintf(&s.sb, "set -e %s\n", name)
+	} else {
+		fmt.Fprintf(&s.sb, "unset %s\n", name)
+	}
+}
+
+func (s *shellScript) String() string {
+	return s.sb.String()
+}
+
+// activeConfigRange returns the GIT_CONFIG_COUNT from before the current
+// activation and the current one. Without an activation, both are the
+// current count.
+func activeConfigRange() (int, int, error) {
+	count, err := parseConfigCount(os.Getenv("GIT_CONFIG_COUNT"))
+	if err != nil {
+		return 0, 0, err
+	}
+	base := count
+	if v, ok := os.LookupEnv(activeBaseEnv); ok && os.Getenv(activeProfileEnv) != "" {
+		base, err = strconv.Atoi(v)
+		if err != nil || base < 0 || base > count {
+			return 0, 0, fmt.Errorf("invalid %s: %s", activeBaseEnv, v)
+		}
+	}
+	return base, count, nil
+}
+
+// activateScript returns the statements that apply profile to the shell,
+// replacing the profile activated before.
+func activateScript(profile Profile) (string, error) {
+	script, err := newShellScript()
+	if err != nil {
+		return "", err
+	}
+	base, count, err := activeConfigRange()
+	if err != nil {
+		return "", err
+	}
+	config, err := manager.LoadConfig(profile)
+	if err != nil {
+		return "", err
+	}
+	vars, err := configEnvVars(config, base)
+	if err != nil {
+		return "", err
+	}
+	// the variables of the previous activation above the new count
+	for i := base + (len(vars)-1)/2; i < count; i++ {
+		script.unset(fmt.Sprintf("GIT_CONFIG_KEY_%d", i))
+		script.unset(fmt.Sprintf("GIT_CONFIG_VALUE_%d", i))
+	}
+	for _, kv := range vars {
+		name, value, _ := strings.Cut(kv, "=")
+		script.set(name, value)
+	}
+	script.set(activeProfileEnv, profile.Name)
+	script.set(activeBaseEnv, strconv.Itoa(base))
+	return script.String(), nil
+}
+
+// deactivateScript returns the statements that remove the activated profile
+// from the shell.
+func deactivateScript() (string, error) {
+	script, err := newShellScript()
+	if err != nil {
+		return "", err
+	}
+	if os.Getenv(activeProfileEnv) == "" {
+		return "", nil
+	}
+	base, count, err := activeConfigRange()
+	if err != nil {
+		return "", err
+	}
+	for i := base; i < count; i++ {
+		script.unset(fmt.Sprintf("GIT_CONFIG_KEY_%d", i))
+		script.unset(fmt.Sprintf("GIT_CONFIG_VALUE_%d", i))
+	}
+	if base == 0 {
+		script.unset("GIT_CONFIG_COUNT")
+	} else {
+		script.set("GIT_CONFIG_COUNT", strconv.Itoa(base))
+	}
+	script.unset(activeProfileEnv)
+	script.unset(activeBaseEnv)
+	return script.String(), nil
+}
+
+// promptProfile returns the name of the profile in effect for the current
+// directory, or an empty string for the default profile. It only reads
+// config files, so it's fast enough to run for every prompt.
+func promptProfile() string {
+	if name := os.Getenv(activeProfileEnv); name != "" {
+		return name
+	}
+	name, err := manager.Current(context.Background(), "")
+	if err != nil || name == defaultConfigName {
+		return ""
+	}
+	return name
+}
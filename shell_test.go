@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestInitScript_Bash(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil || runtime.GOOS == "windows" {
+		t.Skip("bash isn't available")
+	}
+	dir := t.TempDir()
+	// a git-sw whose output creates the file named by $MARK when evaluated
+	stub := filepath.Join(dir, "git-sw")
+	err = os.WriteFile(stub, []byte("#!/bin/sh\necho 'touch \"$MARK\"'\n"), 0o755)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error = %v, want %v", err, nil)
+	}
+	script, err := initScript("bash", stub)
+	if err != nil {
+		t.Fatalf("initScript() error = %v, want %v", err, nil)
+	}
+
+	tests := []struct {
+		args string
+		eval bool
+	}{
+		{"activate work", true},
+		{"-C . --no-tui activate work", true},
+		{"--profile=work deactivate", true},
+		{"-- deactivate", true},
+		{"exec work -- printf x activate", false},
+		{"--no-tui create --profile activate --name A", false},
+		{"--profile activate use", false},
+		{"-C activate list", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			mark := filepath.Join(t.TempDir(), "evaluated")
+			cmd := exec.Command(bash, "-c", script+"git-sw "+tt.args)
+			cmd.Env = append(os.Environ(), "MARK="+mark)
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("bash error = %v, want %v (output %q)", err, nil, out)
+			}
+			_, err = os.Stat(mark)
+			if got := err == nil; got != tt.eval {
+				t.Errorf("git-sw %s evaluated the output = %v, want %v", tt.args, got, tt.eval)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thansetan/git-sw/pkg/gitconfig"
+	"golang.org/x/crypto/ssh"
+)
+
+// expandHome replaces a leading '~' in path with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	return filepath.Join(userHomeDir, path[1:])
+}
+
+// sshIdentityPath returns the absolute path of an SSH identity file, with
+// forward slashes so it can be used as-is in core.sshCommand on every platform.
+func sshIdentityPath(path string) (string, error) {
+	path, err := filepath.Abs(expandHome(path))
+	if err != nil {
+		return "", err
+	}
+	// on Windows the separators are backslashes, which are fine once converted
+	path = filepath.ToSlash(path)
+	if strings.ContainsAny(path, "'\"\\#;\n") {
+		return "", ErrUnsupportedKeyPath
+	}
+	return path, nil
+}
+
+// validateSSHIdentity checks that path is a readable private key and that
+// path + ".pub" holds the matching public key.
+func validateSSHIdentity(path string) error {
+	if filepath.Ext(path) == ".pub" {
+		return ErrInvalidPrivateKeyExt
+	}
+	path, err := sshIdentityPath(path)
+	if err != nil {
+		return err
+	}
+	privContent, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read SSH private key: %w", err)
+	}
+	pubContent, err := os.ReadFile(path + ".pub")
+	if err != nil {
+		return fmt.Errorf("cannot read SSH public key: %w", err)
+	}
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey(pubContent)
+	if err != nil {
+		return fmt.Errorf("invalid SSH public key: %w", err)
+	}
+
+	var privPubKey ssh.PublicKey
+	signer, err := ssh.ParsePrivateKey(privContent)
+	if err != nil {
+		var passphraseErr *ssh.PassphraseMissingError
+		if !errors.As(err, &passphraseErr) {
+			return fmt.Errorf("invalid SSH private key: %w", err)
+		}
+		if passphraseErr.PublicKey == nil { // old PEM format, the public part is encrypted too
+			return nil
+		}
+		privPubKey = passphraseErr.PublicKey
+	} else {
+		privPubKey = signer.PublicKey()
+	}
+	if !bytes.Equal(privPubKey.Marshal(), pubKey.Marshal()) {
+		return ErrSSHKeyMismatch
+	}
+
+	return nil
+}
+
+// sshCommand builds a core.sshCommand value that makes ssh offer only the
+// given identity file.
+func sshCommand(identityPath string) string {
+	return fmt.Sprintf("ssh -i '%s' -o IdentitiesOnly=yes", identityPath)
+}
+
+// sshIdentityFromCommand returns the identity file of a core.sshCommand
+// value created by sshCommand, or an empty string if there's none.
+func sshIdentityFromCommand(command string) string {
+	_, rest, ok := strings.Cut(command, " -i ")
+	if !ok {
+		return ""
+	}
+	rest = strings.TrimLeft(rest, " ")
+	if strings.HasPrefix(rest, "'") {
+		end := strings.IndexByte(rest[1:], '\'')
+		if end == -1 {
+			return ""
+		}
+		return rest[1 : end+1]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+const (
+	sshBlockBegin = "# BEGIN git-sw managed block: "
+	sshBlockEnd   = "# END git-sw managed block: "
+)
+
+// sshHostAlias returns the host alias used for profileName, e.g. "github.com-work".
+func sshHostAlias(host, profileName string) string {
+	alias := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(profileName))
+	return host + "-" + alias
+}
+
+// sshHostBlock renders the managed ~/.ssh/config block of a profile. Its
+// markers are tagged with the host and the profile.
+func sshHostBlock(profileName, host, identityPath string) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "%s%s %s\n", sshBlockBegin, host, profileName)
+	fmt.Fprintf(sb, "Host %s\n", sshHostAlias(host, profileName))
+	fmt.Fprintf(sb, "\tHostName %s\n", host)
+	fmt.Fprintf(sb, "\tUser git\n")
+	fmt.Fprintf(sb, "\tIdentityFile \"%s\"\n", identityPath)
+	fmt.Fprintf(sb, "\tIdentitiesOnly yes\n")
+	fmt.Fprintf(sb, "%s%s %s\n", sshBlockEnd, host, profileName)
+	return sb.String()
+}
+
+// sshBlockHost returns the HostName of the lines of a managed block. Blocks
+// written by older versions aren't tagged with their host, but all have it.
+func sshBlockHost(block []string) string {
+	for _, line := range block {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.EqualFold(fields[0], "HostName") {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// replaceSSHBlocks removes the git-sw managed blocks of host from content and
+// puts blocks in place of the first one, or at the end if there was none.
+// Everything outside of those blocks, including the managed blocks of other
+// hosts, is kept as is.
+func replaceSSHBlocks(content, host string, blocks []string) string {
+	var (
+		lines     []string
+		block     []string // lines of the managed block being read
+		insertAt  = -1
+		inBlock   bool
+		remaining = strings.SplitAfter(content, "\n")
+	)
+	for _, line := range remaining {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, sshBlockBegin):
+			lines = append(lines, block...) // a block that was never ended
+			block, inBlock = []string{line}, true
+		case inBlock:
+			block = append(block, line)
+			if !strings.HasPrefix(trimmed, sshBlockEnd) {
+				continue
+			}
+			if !strings.EqualFold(sshBlockHost(block), host) {
+				lines = append(lines, block...)
+			} else if insertAt == -1 {
+				insertAt = len(lines)
+			}
+			block, inBlock = nil, false
+		case line != "":
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, block...)
+	if insertAt == -1 {
+		insertAt = len(lines)
+		if len(lines) > 0 && len(blocks) > 0 {
+			if !strings.HasSuffix(lines[len(lines)-1], "\n") {
+				lines[len(lines)-1] += "\n"
+			}
+			if strings.TrimSpace(lines[len(lines)-1]) != "" {
+				lines = append(lines, "\n")
+				insertAt++
+			}
+		}
+	}
+
+	sb := new(strings.Builder)
+	for _, line := range lines[:insertAt] {
+		sb.WriteString(line)
+	}
+	for _, block := range blocks {
+		sb.WriteString(block)
+	}
+	for _, line := range lines[insertAt:] {
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// setupSSHHosts writes a managed host alias block to ~/.ssh/config for every
+// profile that has an SSH key, and adds the matching url.<alias>.insteadOf
+// rewrites to the profile's config, recording the files in entry. It returns
+// the profiles that were set up. Profiles whose names give the same alias are
+// an error, and nothing is written.
+func setupSSHHosts(entry *JournalEntry, profiles []Profile, host string) ([]Profile, error) {
+	type sshProfile struct {
+		profile      Profile
+		identityPath string
+	}
+	var (
+		found   []sshProfile
+		aliases = make(map[string]string)
+	)
+	for _, profile := range profiles {
+		if profile.Name == defaultConfigName {
+			continue
+		}
+		config, err := manager.LoadConfig(profile)
+		if err != nil {
+			return nil, err
+		}
+		command, err := config.Get("core.sshCommand")
+		if err != nil {
+			if errors.Is(err, gitconfig.ErrKeyNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		identityPath := sshIdentityFromCommand(command.String())
+		if identityPath == "" {
+			continue
+		}
+		alias := sshHostAlias(host, profile.Name)
+		if other, ok := aliases[alias]; ok {
+			return nil, fmt.Errorf("%w: \"%s\" and \"%s\" are both %s", ErrSSHAliasConflict, other, profile.Name, alias)
+		}
+		aliases[alias] = profile.Name
+		found = append(found, sshProfile{profile, identityPath})
+	}
+
+	sshDir := filepath.Join(userHomeDir, ".ssh")
+	sshConfigPath := filepath.Join(sshDir, "config")
+	err := entry.snapshot(sshConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		blocks     []string
+		configured []Profile
+	)
+	for _, p := range found {
+		configPath, err := manager.ConfigPath(p.profile)
+		if err != nil {
+			return nil, err
+		}
+		err = entry.snapshot(configPath)
+		if err != nil {
+			return nil, err
+		}
+		alias := sshHostAlias(host, p.profile.Name)
+		err = setAllFile(fmt.Sprintf("url.git@%s:.insteadOf", alias), []string{"git@" + host + ":", "ssh://git@" + host + "/"}, configPath)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, sshHostBlock(p.profile.Name, host, p.identityPath))
+		configured = append(configured, p.profile)
+	}
+
+	err = os.MkdirAll(sshDir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(sshConfigPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	err = gitconfig.WriteFileAtomic(sshConfigPath, []byte(replaceSSHBlocks(string(content), host, blocks)), 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return configured, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSSHIdentityPath(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		path    string
+		want    string
+		wantErr error
+	}{
+		{filepath.Join(dir, "keys", "id_ed25519"), filepath.ToSlash(filepath.Join(dir, "keys", "id_ed25519")), nil},
+		{filepath.Join(dir, "it's"), "", ErrUnsupportedKeyPath},
+		{filepath.Join(dir, "#1"), "", ErrUnsupportedKeyPath},
+	}
+	for _, tt := range tests {
+		got, err := sshIdentityPath(tt.path)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("sshIdentityPath(%q) error = %v, want %v", tt.path, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("sshIdentityPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceSSHBlocks(t *testing.T) {
+	oldBlock := sshBlockBegin + "work\nHost github.com-work\n\tHostName github.com\n" + sshBlockEnd + "work\n"
+	gitlabBlock := sshHostBlock("work", "gitlab.com", "/keys/work")
+	newBlock := sshHostBlock("work", "github.com", "/keys/work")
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", newBlock},
+		{"appended", "Host *\n\tAddKeysToAgent yes", "Host *\n\tAddKeysToAgent yes\n\n" + newBlock},
+		{"untagged block replaced", "Host *\n" + oldBlock + "Host x\n", "Host *\n" + newBlock + "Host x\n"},
+		{"other host kept", gitlabBlock + "\n" + oldBlock, gitlabBlock + "\n" + newBlock},
+		{"unended block kept", "Host *\n" + sshBlockBegin + "work\nHost y\n", "Host *\n" + sshBlockBegin + "work\nHost y\n\n" + newBlock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replaceSSHBlocks(tt.content, "github.com", []string{newBlock}); got != tt.want {
+				t.Errorf("replaceSSHBlocks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommands_SSHSetup(t *testing.T) {
+	e := newTestEnv(t, func(out io.Writer) UserInterface {
+		return &NoTUI{Out: out}
+	})
+	withKey := func(name string) {
+		e.mustRun("create " + name + " --name Work --email work@example.com")
+		e.git(e.repo, "config", "--file", filepath.Join(manager.Path(name), ".gitconfig"), "core.sshCommand", sshCommand("/keys/"+name))
+	}
+
+	withKey("a+b")
+	withKey("a-b")
+	err := e.run("ssh-setup")
+	if !errors.Is(err, ErrSSHAliasConflict) {
+		t.Errorf("git-sw ssh-setup error = %v, want %v", err, ErrSSHAliasConflict)
+	}
+	e.mustRun("delete a-b")
+
+	sshDir := filepath.Join(e.app.HomeDir, ".ssh")
+	target := filepath.Join(e.root, "dotfiles", "ssh_config")
+	gitlabBlock := sshHostBlock("a+b", "gitlab.com", "/keys/a+b")
+	for _, dir := range []string{sshDir, filepath.Dir(target)} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatalf("os.MkdirAll() error = %v, want %v", err, nil)
+		}
+	}
+	if err := os.WriteFile(target, []byte(gitlabBlock), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v, want %v", err, nil)
+	}
+	if err := os.Symlink(target, filepath.Join(sshDir, "config")); err != nil {
+		t.Skipf("os.Symlink() error = %v", err)
+	}
+	e.mustRun("ssh-setup")
+	e.mustRun("ssh-setup")
+
+	info, err := os.Lstat(filepath.Join(sshDir, "config"))
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("~/.ssh/config isn't a symlink anymore (error = %v)", err)
+	}
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v, want %v", err, nil)
+	}
+	want := gitlabBlock + "\n" + sshHostBlock("a+b", "github.com", "/keys/a+b")
+	if got := string(content); got != want {
+		t.Errorf("ssh config = %q, want %q", got, want)
+	}
+	if strings.Count(string(content), sshBlockBegin) != 2 {
+		t.Errorf("ssh config has %d managed blocks, want 2", strings.Count(string(content), sshBlockBegin))
+	}
+}
+
+func TestCommands_SSHSetupEditsProfile(t *testing.T) {
+	e := newTestEnv(t, func(out io.Writer) UserInterface {
+		return &NoTUI{Out: out}
+	})
+	e.mustRun("create work --name Work --email work@example.com")
+	configPath := filepath.Join(manager.Path("work"), ".gitconfig")
+	e.git(e.repo, "config", "--file", configPath, "core.sshCommand", sshCommand("/keys/work"))
+	f, err := os.OpenFile(configPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("os.OpenFile() error = %v, want %v", err, nil)
+	}
+	_, err = f.WriteString("# work laptop only\n")
+	f.Close()
+	if err != nil {
+		t.Fatalf("WriteString() error = %v, want %v", err, nil)
+	}
+	before, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v, want %v", err, nil)
+	}
+
+	e.mustRun("ssh-setup")
+	e.mustRun("ssh-setup")
+	after, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v, want %v", err, nil)
+	}
+	if !strings.HasPrefix(string(after), string(before)) {
+		t.Errorf("profile config = %q, want it to start with %q", after, before)
+	}
+	insteadOf := e.git(e.repo, "config", "--file", configPath, "--get-all", "url.git@github.com-work:.insteadOf")
+	if want := "git@github.com:\nssh://git@github.com/"; insteadOf != want {
+		t.Errorf("url.git@github.com-work:.insteadOf = %q, want %q", insteadOf, want)
+	}
+
+	e.mustRun("undo")
+	reverted, err := os.ReadFile(configPath)
+	if err != nil || string(reverted) != string(before) {
+		t.Errorf("profile config after undo = (%q, %v), want (%q, %v)", reverted, err, before, nil)
+	}
+	if _, err := os.Stat(filepath.Join(e.app.HomeDir, ".ssh", "config")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("os.Stat(~/.ssh/config) error = %v, want %v", err, os.ErrNotExist)
+	}
+}
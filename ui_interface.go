@@ -1,5 +1,12 @@
 package main
 
+import (
+	"io"
+	"os"
+
+	"github.com/thansetan/git-sw/pkg/profile"
+)
+
 // UserInterface defines the methods required for user interaction.
 // This abstraction allows for both interactive (TUI) and non-interactive (flag-based) modes.
 type UserInterface interface {
@@ -13,22 +20,73 @@ type UserInterface interface {
 	ConfirmDelete() bool
 	// EditProfile handles the editing of a profile (e.g., opening editor).
 	EditProfile(path string) error
+	// SelectBackup allows the user to select a backup to restore.
+	SelectBackup(backups []Backup) (Backup, error)
+	// RenameProfile gathers the new name of a profile.
+	RenameProfile(profile Profile, profiles []Profile) (string, error)
+	// ListHistory displays the journal entries.
+	ListHistory(entries []JournalEntry) error
+	// ListRepos displays the config files a profile is used in.
+	ListRepos(profile Profile, uses []RepoUse) error
+	// ListScan displays the repositories found by scan.
+	ListScan(results []ScanResult) error
+	// Confirm asks whether to go ahead with the action described by label.
+	Confirm(label string) bool
+	// PromptEmail gathers the user.email of a profile.
+	PromptEmail(profile Profile) (string, error)
 }
 
+// GitRunner makes the commands that run git, see profile.GitRunner.
+type GitRunner = profile.GitRunner
+
 // AppState holds shared application state and dependencies.
 // This replaces global mutable state with an explicit dependency injection pattern.
 type AppState struct {
-	UI UserInterface
+	UI        UserInterface
+	Scope     Scope     // scope requested through -g or --scope, empty if none
+	Args      []string  // positional arguments after the command
+	HomeDir   string    // the user's home directory, which ~ in paths refers to
+	ConfigDir string    // the user config directory, with the settings and, by default, the profiles
+	Git       GitRunner // runs git for the commands
+	Out       io.Writer // the output of the commands
+	In        io.Reader // the input of the commands, read by create --from -
+}
+
+// Arg returns the i'th positional argument after the command, or an empty
+// string if there's no such argument.
+func (a *AppState) Arg(i int) string {
+	if i < 0 || i >= len(a.Args) {
+		return ""
+	}
+	return a.Args[i]
+}
+
+// NewAppState creates a new AppState for the user running git-sw, reading
+// from stdin and writing to stdout. The UI is set once the settings are
+// loaded, see newUI.
+func NewAppState() (*AppState, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &AppState{
+		HomeDir:   homeDir,
+		ConfigDir: configDir,
+		Git:       profile.ExecRunner{},
+		Out:       os.Stdout,
+		In:        os.Stdin,
+	}, nil
 }
 
-// NewAppState creates a new AppState with the appropriate UI implementation
-// based on the noTUI flag.
-func NewAppState(noTUI bool) *AppState {
-	var ui UserInterface
+// newUI returns the appropriate UI implementation based on the noTUI flag,
+// writing to out.
+func newUI(noTUI bool, out io.Writer) UserInterface {
 	if noTUI {
-		ui = &NoTUI{}
-	} else {
-		ui = &TUI{}
+		return &NoTUI{Out: out}
 	}
-	return &AppState{UI: ui}
+	return &TUI{Out: out}
 }
@@ -1,48 +1,89 @@
 package main
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"runtime"
+	"strings"
 
 	"github.com/manifoldco/promptui"
 )
 
-func hash(s string) (string, error) {
-	h := md5.New()
-	_, err := fmt.Fprint(h, s)
-	if err != nil {
-		return "", err
+// colorEnabled is false when NO_COLOR is set or stdout isn't a terminal, in
+// which case output isn't styled.
+var colorEnabled = os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// styler returns style, or a function that doesn't style anything when
+// color is disabled.
+func styler(style func(interface{}) string) func(interface{}) string {
+	if !colorEnabled {
+		return func(v interface{}) string {
+			return fmt.Sprint(v)
+		}
+	}
+	return style
+}
+
+// disablePromptColors removes the colors from the icons and templates of
+// the prompts.
+func disablePromptColors() {
+	promptui.IconInitial, promptui.IconGood, promptui.IconWarn, promptui.IconBad, promptui.IconSelect = "?", "✔", "⚠", "✗", "▸"
+	for name, f := range promptui.FuncMap {
+		if _, ok := f.(func(interface{}) string); ok {
+			promptui.FuncMap[name] = styler(nil)
+		}
 	}
-	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 func formatError(err error) string {
-	label := promptui.Styler(promptui.BGRed, promptui.FGBlack)("ERROR")
-	errMsg := promptui.Styler(promptui.FGRed)(err)
+	label := styler(promptui.Styler(promptui.BGRed, promptui.FGBlack))("ERROR")
+	errMsg := styler(promptui.Styler(promptui.FGRed))(err)
 	return fmt.Sprintf("%s %s", label, errMsg)
 }
 
+// errorEnvelope is how errors are printed with --output json.
+type errorEnvelope struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Status  int    `json:"status"`
+	} `json:"error"`
+}
+
 func errorAndExit(err error) {
 	if errors.Is(err, promptui.ErrInterrupt) {
 		return
 	}
-	fmt.Println(formatError(err))
-	os.Exit(1)
+	code := lookupErrorCode(err)
+	var exitErr exitCodeError
+	if errors.As(err, &exitErr) {
+		code.Status = exitErr.code
+	} else if outputFormat() == jsonOutput {
+		var envelope errorEnvelope
+		envelope.Error.Code, envelope.Error.Message, envelope.Error.Status = code.Code, err.Error(), code.Status
+		json.NewEncoder(os.Stdout).Encode(envelope)
+	} else {
+		fmt.Println(formatError(err))
+	}
+	if heldLock != nil {
+		heldLock.release()
+	}
+	os.Exit(code.Status)
 }
 
 func openTextEditor(filePath string) error {
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "windows":
-		cmd = exec.Command("notepad")
-	default:
-		cmd = exec.Command("vim")
+	editor := strings.Fields(getSetting(editorSettingKey))
+	if len(editor) == 0 {
+		return fmt.Errorf("%w: %s", ErrEmptyField, editorSettingKey)
 	}
+	cmd := exec.Command(editor[0], editor[1:]...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -51,8 +92,15 @@ func openTextEditor(filePath string) error {
 }
 
 func successMessage(profileName string, action Action) string {
-	label := promptui.Styler(promptui.BGGreen, promptui.FGWhite)("SUCCESS")
-	text := promptui.Styler(promptui.FGGreen)(fmt.Sprintf("%s profile \"%s\"", action, profileName))
+	label := styler(promptui.Styler(promptui.BGGreen, promptui.FGWhite))("SUCCESS")
+	text := styler(promptui.Styler(promptui.FGGreen))(fmt.Sprintf("%s profile \"%s\"", action, profileName))
+
+	return fmt.Sprintf("%s %s", label, text)
+}
+
+func warningMessage(msg string) string {
+	label := styler(promptui.Styler(promptui.BGYellow, promptui.FGBlack))("WARNING")
+	text := styler(promptui.Styler(promptui.FGYellow))(msg)
 
 	return fmt.Sprintf("%s %s", label, text)
 }
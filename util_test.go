@@ -0,0 +1,20 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOpenTextEditor_BlankEditor(t *testing.T) {
+	saved := settingValues[editorSettingKey]
+	t.Cleanup(func() { settingValues[editorSettingKey] = saved })
+
+	settingValues[editorSettingKey] = " \t"
+	err := openTextEditor("config")
+	if !errors.Is(err, ErrEmptyField) {
+		t.Errorf("openTextEditor() error = %v, want %v", err, ErrEmptyField)
+	}
+	if err := validateNotEmpty(" \t"); !errors.Is(err, ErrEmptyField) {
+		t.Errorf("validateNotEmpty() error = %v, want %v", err, ErrEmptyField)
+	}
+}